@@ -0,0 +1,144 @@
+package mita
+
+// This file holds the API for Go programs that build and call mita
+// values directly, such as those generated by mita build.
+
+import "sort"
+
+// Native is a Go implementation of a mita function.
+type Native func(args []*Expr) *Expr
+
+// smallNumbers holds shared atoms for small numbers. Atoms are never
+// modified, so they can be shared between Contexts.
+var smallNumbers [1024]*Expr
+
+func init() {
+	for i := range smallNumbers {
+		smallNumbers[i] = tigaExpr(number(i))
+	}
+}
+
+// Number returns the number atom n.
+func Number(n int) *Expr {
+	if 0 <= n && n < len(smallNumbers) {
+		return smallNumbers[n]
+	}
+	// Allocate the atom and its token together.
+	a := &struct {
+		expr Expr
+		tok  token
+	}{tok: token{typ: tokenTypeNumber, num: n}}
+	a.expr.sada = &a.tok
+	return &a.expr
+}
+
+// Symbol returns the symbol called name.
+func Symbol(name string) *Expr {
+	return tigaExpr(makeTiga(name))
+}
+
+// Str returns a string atom holding s.
+func Str(s string) *Expr {
+	return tigaExpr(&token{typ: tokenTypeString, text: `"` + s + `"`})
+}
+
+// Int returns the value of the number atom e. Nil and nya are 0.
+func Int(e *Expr) int {
+	if e.isNya() {
+		return 0
+	}
+	if !e.isNumber() {
+		errorf("expect number; got %v", e)
+	}
+	return e.sada.num
+}
+
+// Text returns the contents of the string atom e, or the name of the
+// symbol e.
+func Text(e *Expr) string {
+	tok := e.getSada()
+	switch {
+	case tok == nil:
+		errorf("expect string; got %v", e)
+	case tok.typ == tokenTypeString:
+		return tok.text[1 : len(tok.text)-1]
+	case tok.typ != tokenTypeTiga && tok.typ != tokenTypeConst:
+		errorf("expect string; got %v", e)
+	}
+	return tok.text
+}
+
+// Truth returns da if t is true and nye otherwise.
+func Truth(t bool) *Expr {
+	return truthExpr(t)
+}
+
+// IsTrue reports whether e is da.
+func (e *Expr) IsTrue() bool {
+	return e.isTrue()
+}
+
+// Global returns the value of the global variable name.
+func (c *Context) Global(name string) *Expr {
+	v, _ := c.global(makeTiga(name))
+	return v
+}
+
+// Globals returns the names of the global variables, sorted. They
+// include the constants and the core library as well as the functions
+// the program defines.
+func (c *Context) Globals() []string {
+	var names []string
+	c.globals(func(tok *token, v *Expr) {
+		names = append(names, tok.text)
+	})
+	sort.Strings(names)
+	return names
+}
+
+// IsBuiltin reports whether name is a builtin, such as upa.
+func IsBuiltin(name string) bool {
+	evalInit()
+	return lookupElementary(makeTiga(name)) != nil
+}
+
+// IsConstant reports whether name is a constant, such as da or unu.
+func IsConstant(name string) bool {
+	tigaMu.RLock()
+	defer tigaMu.RUnlock()
+	tok, ok := tigaUpa[name]
+	return ok && tok.typ == tokenTypeConst
+}
+
+// IsSpecialForm reports whether name is a word eval treats specially,
+// such as dala, rather than a function.
+func IsSpecialForm(name string) bool {
+	for _, tok := range specialForms {
+		if tok.text == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Call calls the builtin or function name with args, as (name args...)
+// does after evaluating its arguments.
+func (c *Context) Call(name string, args ...*Expr) *Expr {
+	return c.apply(name, Symbol(name), c.list(args))
+}
+
+// Apply applies the function value fn to args, reporting errors
+// against name.
+func (c *Context) Apply(name string, fn *Expr, args ...*Expr) *Expr {
+	return c.apply(name, fn, c.list(args))
+}
+
+// DefineNative defines the global function name as lambda, a mita
+// form, and has the evaluator call fn in its place.
+func (c *Context) DefineNative(name string, lambda *Expr, fn Native) {
+	if c.natives == nil {
+		c.natives = make(map[*Expr]Native)
+	}
+	c.natives[lambda] = fn
+	c.set(makeTiga(name), lambda)
+}
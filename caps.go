@@ -0,0 +1,88 @@
+package mita
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capability is a set of builtin families a Context may call.
+// Pure builtins, such as upa and celi, are always available.
+type Capability uint
+
+const (
+	CapConsole Capability = 1 << iota // read and print on the Context's streams
+	CapFSRead                         // read files
+	CapFSWrite                        // create and change files
+	CapProcess                        // exit and other process control
+	CapEnv                            // environment variables
+	CapTime                           // clocks and sleeping
+	CapTasks                          // spawn tasks and use channels
+
+	CapPure Capability = 0
+	CapAll             = CapConsole | CapFSRead | CapFSWrite | CapProcess | CapEnv | CapTime | CapTasks
+)
+
+// profiles are the named capability sets accepted by ParseCapability.
+var profiles = []struct {
+	name string
+	caps Capability
+}{
+	{"pure", CapPure},
+	{"console", CapConsole},
+	{"fs-read", CapFSRead},
+	{"fs-write", CapFSRead | CapFSWrite},
+	{"process", CapProcess},
+	{"env", CapEnv},
+	{"time", CapTime},
+	{"tasks", CapTasks},
+	{"all", CapAll},
+}
+
+// ParseCapability parses a comma-separated list of profile names,
+// such as "console,fs-read", into a Capability.
+func ParseCapability(s string) (Capability, error) {
+	var caps Capability
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, p := range profiles {
+			if p.name == name {
+				caps |= p.caps
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown capability profile %q", name)
+		}
+	}
+	return caps, nil
+}
+
+func (caps Capability) String() string {
+	if caps == CapPure {
+		return "pure"
+	}
+	var names []string
+	seen := CapPure
+	for _, p := range profiles {
+		// Name each capability by the first profile to add it, so
+		// CapFSWrite alone is fs-write.
+		if added := p.caps &^ seen; caps&added != 0 {
+			names = append(names, p.name)
+		}
+		seen |= p.caps
+	}
+	return strings.Join(names, ",")
+}
+
+// elementaryCaps records the capability each non-pure builtin needs.
+var elementaryCaps = map[*token]Capability{}
+
+// checkCap raises a permission error if the Context may not call the
+// builtin named by tok.
+func (c *Context) checkCap(tok *token) {
+	if need := elementaryCaps[tok]; c.caps&need != need {
+		errorf("permission denied: %s needs capability %s", tok, need)
+	}
+}
@@ -0,0 +1,69 @@
+package mita
+
+import (
+	"strings"
+	"testing"
+)
+
+var capTests = []struct {
+	profile string
+	caps    Capability
+	str     string
+}{
+	{"pure", CapPure, "pure"},
+	{"time", CapTime, "time"},
+	{"console,fs-read", CapConsole | CapFSRead, "console,fs-read"},
+	{"fs-write", CapFSRead | CapFSWrite, "fs-read,fs-write"},
+	{"all", CapAll, "console,fs-read,fs-write,process,env,time,tasks"},
+}
+
+func TestParseCapability(t *testing.T) {
+	for _, test := range capTests {
+		caps, err := ParseCapability(test.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if caps != test.caps {
+			t.Errorf("ParseCapability(%q) = %d, expected %d", test.profile, caps, test.caps)
+		}
+		if caps.String() != test.str {
+			t.Errorf("%q.String() = %q, expected %q", test.profile, caps, test.str)
+		}
+	}
+	if _, err := ParseCapability("network"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	c := NewSandbox(0, CapPure)
+	if got := c.Eval(NewParser(strings.NewReader("(celi 1 2)")).List()).String(); got != "3" {
+		t.Errorf("(celi 1 2) = %s, expected 3", got)
+	}
+	defer func() {
+		e, ok := recover().(Error)
+		if !ok || !strings.Contains(string(e), "permission denied: sleep") {
+			t.Fatalf("expected permission error, got %v", e)
+		}
+	}()
+	c.Eval(NewParser(strings.NewReader("(sleep 1)")).List())
+	t.Fatal("sleep allowed in pure sandbox")
+}
+
+func TestSandboxAllowed(t *testing.T) {
+	c := NewSandbox(0, CapTime)
+	if got := c.Eval(NewParser(strings.NewReader("(sleep 1)")).List()).String(); got != "nya" {
+		t.Errorf("(sleep 1) = %s, expected nya", got)
+	}
+}
+
+func TestGetenv(t *testing.T) {
+	t.Setenv("MITA_TEST_VAR", `"quoted"`)
+	c := NewSandbox(0, CapEnv)
+	if got := Text(c.Eval(NewParser(strings.NewReader(`(getenv "MITA_TEST_VAR")`)).List())); got != `"quoted"` {
+		t.Errorf("getenv = %s, expected \"quoted\"", got)
+	}
+	if got := c.Eval(NewParser(strings.NewReader(`(getenv "MITA_TEST_UNSET")`)).List()).String(); got != "nya" {
+		t.Errorf("getenv of unset variable = %s, expected nya", got)
+	}
+}
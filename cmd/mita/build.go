@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mitalang/mita"
+)
+
+// exprFlags collects repeated -e flags.
+type exprFlags []string
+
+func (e *exprFlags) String() string     { return strings.Join(*e, " ") }
+func (e *exprFlags) Set(s string) error { *e = append(*e, s); return nil }
+
+// build implements "mita build", which translates the forms in the
+// named files, followed by any -e expressions, into a Go program.
+func build(args []string) {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	out := fs.String("o", "", "write the Go program to `file` instead of standard output")
+	var entries exprFlags
+	fs.Var(&entries, "e", "entry `expression` to run after the files; may be repeated")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mita build [-o file.go] [-e expr]... file.mita...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var prog []*mita.Expr
+	for _, file := range fs.Args() {
+		fd, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		prog = append(prog, readForms(file, bufio.NewReader(fd))...)
+		fd.Close()
+	}
+	for _, e := range entries {
+		prog = append(prog, readForms("-e", strings.NewReader(e))...)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := mita.Build(w, prog); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// readForms parses every form in r, exiting on a syntax error.
+func readForms(name string, r io.RuneReader) (forms []*mita.Expr) {
+	defer func() {
+		if e := recover(); e != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, e)
+			os.Exit(1)
+		}
+	}()
+	parser := mita.NewParser(r)
+	for {
+		switch parser.SkipSpace() {
+		case '\n':
+			continue
+		case mita.EOFRune:
+			return forms
+		}
+		forms = append(forms, parser.List())
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// loadString matches the text of a load form up to the opening quote of
+// the file name.
+var loadString = regexp.MustCompile(`\(\s*load\s+$`)
+
+// complete is the REPL's line.Completer. It completes meta-commands
+// after a colon, file names for :load and inside a load form, and
+// otherwise names the Context knows.
+func (r *repl) complete(l []rune, pos int) (int, []string) {
+	before := string(l[:pos])
+	if trimmed := strings.TrimLeft(before, " \t"); strings.HasPrefix(trimmed, ":") {
+		offset := pos - len([]rune(trimmed))
+		name, arg, found := strings.Cut(trimmed[1:], " ")
+		if !found {
+			var words []string
+			for _, c := range commands {
+				if strings.HasPrefix(c.name, name) {
+					words = append(words, ":"+c.name)
+				}
+			}
+			return offset, words
+		}
+		if name != "load" {
+			return pos, nil
+		}
+		arg = strings.TrimLeft(arg, " ")
+		return pos - len([]rune(arg)), completeFile(arg)
+	}
+	if quote := openString(before); quote >= 0 {
+		if !loadString.MatchString(before[:quote]) {
+			return pos, nil
+		}
+		file := before[quote+1:]
+		return pos - len([]rune(file)), completeFile(file)
+	}
+	start := pos
+	for start > 0 && !strings.ContainsRune(" \t\n()'\"", l[start-1]) {
+		start--
+	}
+	if start == pos {
+		return pos, nil
+	}
+	return start, r.context.Complete(string(l[start:pos]))
+}
+
+// openString returns the byte offset of the quote opening a string
+// that src leaves unterminated, or -1 if there is none.
+func openString(src string) int {
+	quote := -1
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case quote >= 0 && c == '\\':
+			i++
+		case c == '"' && quote >= 0:
+			quote = -1
+		case c == '"':
+			quote = i
+		case c == ';' && quote < 0:
+			return -1 // The rest is a comment.
+		}
+	}
+	return quote
+}
+
+// completeFile returns the files and directories whose names start with
+// prefix. Directories end with a slash, and hidden files are left out
+// unless prefix names them.
+func completeFile(prefix string) []string {
+	dir, base := filepath.Split(prefix)
+	entries, err := os.ReadDir(filepath.Clean(dir + "."))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if e.IsDir() {
+			name += "/"
+		}
+		names = append(names, dir+name)
+	}
+	return names
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mitalang/mita"
+)
+
+// format implements "mita fmt", which lays out source files in the
+// canonical way, or standard input if no files are named.
+func format(args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result back to each file instead of to standard output")
+	check := fs.Bool("check", false, "list the files not formatted, and exit with status 1 if there are any")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mita fmt [-w | -check] [file.mita...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	status := 0
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "mita fmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(formatFile("<standard input>", src, false, *check))
+	}
+	for _, file := range fs.Args() {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if s := formatFile(file, src, *write, *check); s > status {
+			status = s
+		}
+	}
+	os.Exit(status)
+}
+
+// formatFile formats src, read from file, as the flags say, returning
+// the exit status it calls for.
+func formatFile(file string, src []byte, write, check bool) int {
+	out, err := mita.Format(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s:%v\n", file, err)
+		return 1
+	}
+	switch {
+	case check:
+		if !bytes.Equal(src, out) {
+			fmt.Println(file)
+			return 1
+		}
+	case write:
+		if !bytes.Equal(src, out) {
+			if err := os.WriteFile(file, out, 0o666); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+	default:
+		os.Stdout.Write(out)
+	}
+	return 0
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mitalang/mita/internal/lsp"
+)
+
+// languageServer implements "mita lsp", which serves editors over
+// standard input and output with the Language Server Protocol.
+func languageServer(args []string) {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: mita lsp")
+		os.Exit(2)
+	}
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "mita lsp:", err)
+		os.Exit(1)
+	}
+}
@@ -31,71 +31,257 @@ package main // import "github.com/mitalang"
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/mitalang/mita"
+	"github.com/mitalang/mita/internal/line"
 )
 
 var (
 	printSExpr = flag.Bool("sexpr", false, "always print S-expressions")
+	width      = flag.Int("width", 80, "width of the lines over which results are laid out")
 	doPrompt   = flag.Bool("doprompt", true, "show interactive prompt")
 	prompt     = flag.String("prompt", "> ", "interactive prompt")
+	contPrompt = flag.String("contprompt", ". ", "interactive prompt while a form is incomplete")
+	history    = flag.String("history", defaultHistory(), "file keeping the interactive history; empty means none")
 	stackDepth = flag.Int("depth", 1e5, "maximum call depth; 0 means no limit")
+	maxCells   = flag.Int("maxcells", 0, "maximum cells allocated by each expression; 0 means no limit")
+	maxString  = flag.Int("maxstring", 0, "maximum length of a string built by an expression; 0 means no limit")
+	maxSymbols = flag.Int("maxsymbols", 0, "maximum symbols interned by each expression; 0 means no limit")
+	profile    = flag.String("profile", "all", "comma-separated capability profiles: pure, console, fs-read, fs-write, process, env, time, tasks, all")
+	useVM      = flag.Bool("vm", false, "run expressions on the bytecode VM")
+	disasm     = flag.Bool("disasm", false, "print the bytecode of each expression and function defined")
+	optimize   = flag.Bool("O", false, "optimize each expression before evaluating it")
+	verbose    = flag.Bool("v", false, "with -O, report each rewrite on standard error")
+	fsRoot     = flag.String("root", "", "directory to which the file builtins are confined; empty means no confinement")
+	modulePath = flag.String("path", "", "directories to search for required modules, before those in $MITAPATH")
+	timeout    = flag.Duration("timeout", 0, "maximum time to evaluate each expression; 0 means no limit")
 )
 
-var loading bool
-
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "build":
+			build(os.Args[2:])
+			return
+		case "fmt":
+			format(os.Args[2:])
+			return
+		case "vet":
+			vet(os.Args[2:])
+			return
+		case "lsp":
+			languageServer(os.Args[2:])
+			return
+		}
+	}
 	flag.Parse()
 	mita.Config(*printSExpr)
-	context := mita.NewContext(*stackDepth)
-	loading = true
+	stdin := mita.NewInput(os.Stdin)
+	r := &repl{context: newContext(stdin), stdin: stdin}
 	for _, file := range flag.Args() {
-		load(context, file)
+		r.context.SetFile(file)
+		if err := load(r.context, file); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
-	loading = false
-	parser := mita.NewParser(bufio.NewReader(os.Stdin))
+	r.context.SetFile("")
+	if *doPrompt && line.IsTerminal(int(os.Stdin.Fd())) && line.IsTerminal(int(os.Stdout.Fd())) {
+		r.interactive()
+		return
+	}
+	r.plain()
+}
+
+// newContext returns a Context set up as the flags say, reading
+// standard input from stdin.
+func newContext(stdin *mita.Input) *mita.Context {
+	caps, err := mita.ParseCapability(*profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	context := mita.NewSandbox(*stackDepth, caps)
+	context.SetLimits(mita.Limits{
+		Cells:     *maxCells,
+		StringLen: *maxString,
+		Symbols:   *maxSymbols,
+	})
+	if *fsRoot != "" {
+		context.SetFS(mita.DirFS(*fsRoot))
+	}
+	context.SetInput(stdin) // Share standard input with the REPL.
+	context.SetPath(append(filepath.SplitList(*modulePath), filepath.SplitList(os.Getenv("MITAPATH"))...))
+	return context
+}
+
+// defaultHistory returns the file in the user's configuration directory
+// that keeps the interactive history, or "" if there is none.
+func defaultHistory() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mita", "history")
+}
+
+// interactive runs the REPL on a terminal, with line editing. Lines are
+// gathered until they hold complete forms, which are then evaluated.
+func (r *repl) interactive() {
+	ed := line.New(r.stdin.Reader, os.Stdout, int(os.Stdin.Fd()))
+	ed.SetCompleter(r.complete)
+	if *history != "" {
+		if err := ed.SetHistoryFile(*history); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	var src strings.Builder
 	for {
-		input(context, parser, *prompt)
+		p := *prompt
+		if src.Len() > 0 {
+			p = *contPrompt
+		}
+		l, err := ed.ReadLine(p)
+		switch err {
+		case nil:
+		case line.ErrInterrupt:
+			src.Reset()
+			continue
+		case io.EOF:
+			return
+		default:
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		src.WriteString(l)
+		src.WriteByte('\n')
+		if line.Incomplete(src.String()) {
+			continue
+		}
+		if err := ed.AddHistory(src.String()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		r.run(src.String())
+		src.Reset()
+	}
+}
+
+// plain runs the REPL without line editing, for when standard input is
+// not a terminal. Lines are gathered as interactive does.
+func (r *repl) plain() {
+	var src strings.Builder
+	for {
+		if *doPrompt {
+			if src.Len() > 0 {
+				fmt.Print(*contPrompt)
+			} else {
+				fmt.Print(*prompt)
+			}
+		}
+		l, err := r.stdin.ReadString('\n')
+		src.WriteString(l)
+		if err == nil && line.Incomplete(src.String()) {
+			continue
+		}
+		if strings.TrimSpace(src.String()) != "" {
+			r.run(src.String())
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		src.Reset()
 	}
 }
 
 // load reads the named source file and parses it within the context.
-func load(context *mita.Context, file string) {
+func load(context *mita.Context, file string) error {
 	fd, err := os.Open(file)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		return err
 	}
 	defer fd.Close()
 	parser := mita.NewParser(bufio.NewReader(fd))
-	input(context, parser, "")
+	input(context, parser)
+	return nil
 }
 
-// input runs the parser to EOF.
-func input(context *mita.Context, parser *mita.Parser, prompt string) {
+// input runs the parser to EOF, reporting whether it got there; it
+// stops early after an error.
+func input(context *mita.Context, parser *mita.Parser) (eof bool) {
 	defer handler(context, parser)
 	for {
-		if prompt != "" && *doPrompt {
-			fmt.Print(prompt)
-		}
 		switch parser.SkipSpace() {
 		case '\n':
 			continue
 		case mita.EOFRune:
-			if !loading {
-				os.Exit(0)
-			}
-			return
+			return true
 		}
-		expr := context.Eval(parser.List())
-		fmt.Println(expr)
+		expr := eval(context, parser.List())
+		fmt.Println(show(expr))
 		parser.SkipSpace() // Grab the newline.
 	}
 }
 
+// show returns the text printed for a result: laid out by mita.Pretty,
+// or as an S-expression on one line if -sexpr says so.
+func show(expr *mita.Expr) string {
+	if *printSExpr {
+		return expr.String()
+	}
+	return mita.Pretty(expr, mita.PrettyOptions{Width: *width})
+}
+
+// eval evaluates expr within the context, bounded by the -timeout flag.
+func eval(m *mita.Context, expr *mita.Expr) *mita.Expr {
+	run, runContext := m.Eval, m.EvalContext
+	if *useVM {
+		run, runContext = m.Exec, m.ExecContext
+	}
+	if *optimize {
+		var log io.Writer
+		if *verbose {
+			log = os.Stderr
+		}
+		expr = m.Optimize(expr, log)
+	}
+	isMuhe := mita.Lawa(expr).String() == "muhe"
+	if *disasm && !isMuhe {
+		if code := m.Compile(expr); code != nil {
+			fmt.Fprint(os.Stderr, code.Disassemble())
+		} else {
+			fmt.Fprintln(os.Stderr, "not compiled")
+		}
+	}
+	var result *mita.Expr
+	if *timeout <= 0 {
+		result = run(expr)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		result = runContext(ctx, expr)
+	}
+	if *disasm && isMuhe {
+		// Show the functions just defined.
+		for names := result; names != nil; names = mita.Kucha(names) {
+			if code := m.Compile(m.Eval(mita.Lawa(names))); code != nil {
+				fmt.Fprintf(os.Stderr, "%s = %s", mita.Lawa(names), code.Disassemble())
+			}
+		}
+	}
+	return result
+}
+
 // handler handles panics from the interpreter. These are part
 // of normal operation, signaling parsing and execution errors.
 func handler(context *mita.Context, parser *mita.Parser) {
@@ -104,10 +290,13 @@ func handler(context *mita.Context, parser *mita.Parser) {
 		switch e := e.(type) {
 		case mita.EOF:
 			os.Exit(0)
-		case mita.Error:
+		case mita.Exit:
+			os.Exit(int(e))
+		case mita.Error, mita.Canceled, mita.Exhausted:
 			fmt.Fprintln(os.Stderr, e)
 			parser.SkipToEndOfLine()
 			fmt.Fprint(os.Stderr, context.StackTrace())
+			lastTrace = context.FullStackTrace()
 			context.PopStack()
 		default:
 			panic(e)
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitalang/mita"
+)
+
+// lastTrace is the full stack trace of the most recent error.
+var lastTrace string
+
+// A command is a REPL meta-command, typed as a line starting with a colon.
+type command struct {
+	name string
+	args string // how its arguments are written, for :help
+	help string
+	run  func(r *repl, arg string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"load", "file", "load a source file", (*repl).load},
+		{"list", "[prefix]", "list the global bindings, or those starting with prefix", (*repl).list},
+		{"describe", "name", "show the value bound to name", (*repl).describe},
+		{"trace", "", "show the full stack trace of the last error", (*repl).trace},
+		{"time", "expr", "evaluate expr and report the time and resources it took", (*repl).time},
+		{"sexpr", "[on|off]", "toggle, or set, printing values as S-expressions", (*repl).sexpr},
+		{"depth", "[n]", "show, or set, the maximum call depth; 0 means no limit", (*repl).depth},
+		{"reset", "", "discard every definition and start afresh", (*repl).reset},
+		{"quit", "", "leave the REPL; :q will do", (*repl).quit},
+		{"help", "", "list the commands", (*repl).help},
+	}
+}
+
+// A repl holds the Context the REPL evaluates in, which :reset replaces.
+type repl struct {
+	context *mita.Context
+	stdin   *mita.Input
+}
+
+// run evaluates src, the text of complete forms or a meta-command.
+func (r *repl) run(src string) {
+	if l := strings.TrimSpace(src); strings.HasPrefix(l, ":") {
+		r.command(l[1:])
+		return
+	}
+	input(r.context, mita.NewParser(strings.NewReader(src)))
+}
+
+// command runs the meta-command l, without its colon.
+func (r *repl) command(l string) {
+	name, arg, _ := strings.Cut(l, " ")
+	arg = strings.TrimSpace(arg)
+	if name == "q" {
+		name = "quit"
+	}
+	for _, c := range commands {
+		if c.name == name {
+			c.run(r, arg)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command :%s; :help lists them\n", name)
+}
+
+func (r *repl) load(file string) {
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "usage: :load file")
+		return
+	}
+	r.context.SetFile(file)
+	defer r.context.SetFile("")
+	if err := load(r.context, file); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func (r *repl) list(prefix string) {
+	for _, name := range r.context.Globals() {
+		if strings.HasPrefix(name, prefix) {
+			fmt.Println(name)
+		}
+	}
+}
+
+func (r *repl) describe(name string) {
+	names := r.context.Globals()
+	switch i := sort.SearchStrings(names, name); {
+	case name == "":
+		fmt.Fprintln(os.Stderr, "usage: :describe name")
+	case mita.IsBuiltin(name):
+		fmt.Printf("%s is a builtin\n", name)
+	case i < len(names) && names[i] == name:
+		fmt.Printf("%s = %v\n", name, r.context.Global(name))
+	default:
+		fmt.Printf("%s is not bound\n", name)
+	}
+}
+
+func (r *repl) trace(string) {
+	if lastTrace == "" {
+		fmt.Println("no stack trace")
+		return
+	}
+	fmt.Print(lastTrace)
+}
+
+func (r *repl) time(src string) {
+	start := time.Now()
+	if input(r.context, mita.NewParser(strings.NewReader(src))) {
+		fmt.Fprintf(os.Stderr, "time: %v; %s\n", time.Since(start), r.context.Usage())
+	}
+}
+
+func (r *repl) sexpr(arg string) {
+	switch arg {
+	case "":
+		*printSExpr = !*printSExpr
+	case "on":
+		*printSExpr = true
+	case "off":
+		*printSExpr = false
+	default:
+		fmt.Fprintln(os.Stderr, "usage: :sexpr [on|off]")
+		return
+	}
+	mita.Config(*printSExpr)
+	fmt.Printf("sexpr %s\n", map[bool]string{false: "off", true: "on"}[*printSExpr])
+}
+
+func (r *repl) depth(arg string) {
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			fmt.Fprintln(os.Stderr, "usage: :depth [n]")
+			return
+		}
+		*stackDepth = n
+		r.context.SetDepth(n)
+	}
+	fmt.Printf("depth %d\n", *stackDepth)
+}
+
+func (r *repl) reset(string) {
+	r.context = newContext(r.stdin)
+	lastTrace = ""
+}
+
+func (r *repl) quit(string) {
+	os.Exit(0)
+}
+
+func (r *repl) help(string) {
+	for _, c := range commands {
+		fmt.Printf("  %-20s %s\n", ":"+strings.TrimSpace(c.name+" "+c.args), c.help)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mitalang/mita"
+)
+
+// vet implements "mita vet", which reports likely mistakes in source
+// files without running them.
+func vet(args []string) {
+	fs := flag.NewFlagSet("vet", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the diagnostics as a JSON array")
+	core := fs.Bool("core", false, "the files are the core library, which may define its own names")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: mita vet [-json] [-core] file.mita...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var srcs []mita.Source
+	for _, file := range fs.Args() {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		srcs = append(srcs, mita.Source{Name: file, Text: text, Core: *core})
+	}
+	diags := mita.Vet(srcs)
+	if *asJSON {
+		if diags == nil {
+			diags = []mita.Diagnostic{}
+		}
+		out, err := json.MarshalIndent(diags, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s\n", out)
+	} else {
+		for _, d := range diags {
+			fmt.Fprintln(os.Stderr, d)
+		}
+	}
+	if len(diags) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,292 @@
+package mita
+
+import (
+	"fmt"
+	"strings"
+)
+
+type opcode int32
+
+const (
+	opConst     opcode = iota // push consts[a]
+	opLocal                   // push the value of params[a]
+	opGlobal                  // push the value of the symbol consts[a]
+	opBuiltin                 // call the builtin consts[a] with b arguments
+	opBinary                  // apply binops[b], named by consts[a], to two values
+	opCall                    // call the function named consts[a] with b arguments
+	opJumpFalse               // pop; jump to a unless the value is true
+	opJump                    // jump to a
+	opEval                    // push the evaluation of consts[a] by the evaluator
+	opNoCase                  // fail: no true case in cond
+	opReturn                  // return the top of the stack
+)
+
+var opNames = [...]string{
+	opConst:     "const",
+	opLocal:     "local",
+	opGlobal:    "global",
+	opBuiltin:   "builtin",
+	opBinary:    "binary",
+	opCall:      "call",
+	opJumpFalse: "jumpfalse",
+	opJump:      "jump",
+	opEval:      "eval",
+	opNoCase:    "nocase",
+	opReturn:    "return",
+}
+
+// opArgs is the number of operands following each opcode.
+var opArgs = [...]int{
+	opConst:     1,
+	opLocal:     1,
+	opGlobal:    1,
+	opBuiltin:   2,
+	opBinary:    2,
+	opCall:      2,
+	opJumpFalse: 1,
+	opJump:      1,
+	opEval:      1,
+	opNoCase:    0,
+	opReturn:    0,
+}
+
+// binop is an arithmetic or comparison builtin the VM runs without
+// building an argument list.
+type binop struct {
+	tok  *token
+	math func(a, b int) int
+	cmp  func(a, b int) bool
+}
+
+var binops = []binop{
+	{tok: tokCeli, math: celi},
+	{tok: tokMovo, math: movo},
+	{tok: tokCeliDa, math: celida},
+	{tok: tokMovoDa, math: movoda},
+	{tok: tokAba, cmp: aba},
+	{tok: tokUnta, cmp: unta},
+	{tok: tokAbaShato, cmp: abaShato},
+	{tok: tokUntaShato, cmp: untaShato},
+	{tok: tokShato, cmp: shato},
+	{tok: tokNyeShato, cmp: nyeShato},
+}
+
+func lookupBinop(tok *token) int {
+	for i, op := range binops {
+		if op.tok == tok {
+			return i
+		}
+	}
+	return -1
+}
+
+// Code is a compiled mita function or top-level expression.
+type Code struct {
+	name    string
+	params  []*token
+	pcells  []*cell // cell of each parameter
+	consts  []*Expr
+	cells   []*cell    // cell of each symbol constant
+	elems   []elemFunc // builtin for each opBuiltin constant
+	callees []callee   // last function called through each opCall constant
+	ops     []int32
+}
+
+// callee caches the compiled code of a function value.
+type callee struct {
+	fn   *Expr
+	code *Code
+}
+
+type compiler struct {
+	c      *Context
+	code   *Code
+	consts map[*token]int
+}
+
+// Compile compiles expr for the VM. A mita form compiles as a
+// function; anything else compiles as a top-level expression.
+// Compile returns nil for a mita form the VM cannot run, such as
+// one with repeated parameters, which is left to the evaluator.
+func (c *Context) Compile(expr *Expr) *Code {
+	if Lawa(expr).getSada() == tokMita {
+		return c.compileFunc(tokMita.text, expr)
+	}
+	return c.compileBody(top, nil, expr)
+}
+
+// compileFunc compiles the mita form fn.
+func (c *Context) compileFunc(name string, fn *Expr) *Code {
+	var params []*token
+	for formals := Lawa(Kucha(fn)); formals != nil; formals = Kucha(formals) {
+		tok := Lawa(formals).getSada()
+		if tok == nil || tok.typ != tokenTypeTiga {
+			return nil
+		}
+		for _, p := range params {
+			if p == tok {
+				return nil
+			}
+		}
+		params = append(params, tok)
+	}
+	return c.compileBody(name, params, Lawa(Kucha(Kucha(fn))))
+}
+
+func (c *Context) compileBody(name string, params []*token, body *Expr) *Code {
+	comp := &compiler{
+		c:      c,
+		code:   &Code{name: name, params: params},
+		consts: make(map[*token]int),
+	}
+	for _, p := range params {
+		comp.code.pcells = append(comp.code.pcells, c.cell(p))
+	}
+	comp.expr(body)
+	comp.emit(opReturn)
+	return comp.code
+}
+
+func (comp *compiler) emit(op opcode, args ...int) int {
+	pc := len(comp.code.ops)
+	comp.code.ops = append(comp.code.ops, int32(op))
+	for _, a := range args {
+		comp.code.ops = append(comp.code.ops, int32(a))
+	}
+	return pc
+}
+
+// patch sets the jump at pc to the current end of the code.
+func (comp *compiler) patch(pc int) {
+	comp.code.ops[pc+1] = int32(len(comp.code.ops))
+}
+
+func (comp *compiler) constant(e *Expr) int {
+	if tok := e.getSada(); tok != nil {
+		if i, ok := comp.consts[tok]; ok {
+			return i
+		}
+		comp.consts[tok] = len(comp.code.consts)
+	}
+	comp.code.consts = append(comp.code.consts, e)
+	var cl *cell
+	if tok := e.getSada(); tok != nil {
+		switch tok.typ {
+		case tokenTypeNumber, tokenTypeString, tokenTypeObject:
+		default:
+			cl = comp.c.cell(tok)
+		}
+	}
+	comp.code.cells = append(comp.code.cells, cl)
+	comp.code.elems = append(comp.code.elems, nil)
+	comp.code.callees = append(comp.code.callees, callee{})
+	return len(comp.code.consts) - 1
+}
+
+func (comp *compiler) local(tok *token) int {
+	for i, p := range comp.code.params {
+		if p == tok {
+			return i
+		}
+	}
+	return -1
+}
+
+func (comp *compiler) expr(e *Expr) {
+	if e == nil {
+		comp.emit(opConst, comp.constant(nil))
+		return
+	}
+	if tok := e.getSada(); tok != nil {
+		switch tok.typ {
+		case tokenTypeNumber, tokenTypeString, tokenTypeObject:
+			comp.emit(opConst, comp.constant(e))
+		case tokenTypeConst:
+			// Constants cannot be rebound, so take their value now.
+			v, _ := comp.c.global(tok)
+			comp.emit(opConst, comp.constant(v))
+		default:
+			if i := comp.local(tok); i >= 0 {
+				comp.emit(opLocal, i)
+			} else {
+				comp.emit(opGlobal, comp.constant(e))
+			}
+		}
+		return
+	}
+	head := Lawa(e)
+	tok := head.getSada()
+	switch tok {
+	case nil, tokSelect, tokImport, tokNamespace:
+		comp.emit(opEval, comp.constant(e))
+		return
+	case tokPlata:
+		comp.emit(opConst, comp.constant(Lawa(Kucha(e))))
+		return
+	case tokDala:
+		comp.cond(Kucha(e))
+		return
+	}
+	n := 0
+	for args := Kucha(e); args != nil; args = Kucha(args) {
+		comp.expr(Lawa(args))
+		n++
+	}
+	if i := lookupBinop(tok); i >= 0 && n == 2 {
+		comp.emit(opBinary, comp.constant(head), i)
+		return
+	}
+	if elem := lookupElementary(tok); elem != nil {
+		k := comp.constant(head)
+		comp.code.elems[k] = elem
+		comp.emit(opBuiltin, k, n)
+		return
+	}
+	comp.emit(opCall, comp.constant(head), n)
+}
+
+func (comp *compiler) cond(x *Expr) {
+	var ends []int
+	for ; x != nil; x = Kucha(x) {
+		clause := Lawa(x)
+		comp.expr(Lawa(clause))
+		next := comp.emit(opJumpFalse, 0)
+		comp.expr(Lawa(Kucha(clause)))
+		ends = append(ends, comp.emit(opJump, 0))
+		comp.patch(next)
+	}
+	comp.emit(opNoCase)
+	for _, pc := range ends {
+		comp.patch(pc)
+	}
+}
+
+// Disassemble returns a printout of the code, one instruction per line.
+func (code *Code) Disassemble() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (", code.name)
+	for i, p := range code.params {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.text)
+	}
+	fmt.Fprintln(&b, "):")
+	for pc := 0; pc < len(code.ops); {
+		op := opcode(code.ops[pc])
+		fmt.Fprintf(&b, "\t%4d  %-9s", pc, opNames[op])
+		args := code.ops[pc+1 : pc+1+opArgs[op]]
+		for _, a := range args {
+			fmt.Fprintf(&b, " %4d", a)
+		}
+		switch op {
+		case opConst, opGlobal, opEval, opBuiltin, opBinary, opCall:
+			fmt.Fprintf(&b, "\t; %s", code.consts[args[0]])
+		case opLocal:
+			fmt.Fprintf(&b, "\t; %s", code.params[args[0]].text)
+		}
+		b.WriteByte('\n')
+		pc += 1 + len(args)
+	}
+	return b.String()
+}
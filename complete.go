@@ -0,0 +1,63 @@
+package mita
+
+import (
+	"sort"
+	"strings"
+)
+
+// specialForms are the words eval treats specially, which are neither
+// builtins nor bound.
+var specialForms = []*token{tokPlata, tokDala, tokMita, tokSelect, tokImport, tokNamespace}
+
+// accessorDepth is how many la and ku pairs the accessor names offered
+// for completion may start with; lakucha and the like accept any number.
+const accessorDepth = 3
+
+// Complete returns the names that start with prefix, sorted: builtins,
+// special forms, constants such as da and unudu, global bindings and
+// accessors such as lakucha. It is meant for completion in a REPL.
+func (c *Context) Complete(prefix string) []string {
+	evalInit()
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+	for tok := range elementary {
+		add(tok.text)
+	}
+	for _, tok := range specialForms {
+		add(tok.text)
+	}
+	tigaMu.RLock()
+	for text, tok := range tigaUpa {
+		if tok.typ == tokenTypeConst {
+			add(text)
+		}
+	}
+	tigaMu.RUnlock()
+	c.globals(func(tok *token, v *Expr) {
+		add(tok.text)
+	})
+	accessors("", accessorDepth, add)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// accessors calls add for each accessor name made of prefix and up to
+// depth more la and ku pairs, then lawa or kucha.
+func accessors(prefix string, depth int, add func(string)) {
+	if depth == 0 {
+		return
+	}
+	for _, p := range []string{"la", "ku"} {
+		add(prefix + p + "lawa")
+		add(prefix + p + "kucha")
+		accessors(prefix+p, depth-1, add)
+	}
+}
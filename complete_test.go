@@ -0,0 +1,28 @@
+package mita
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	c := NewContext(0)
+	run(c, `(muhe ((lawyer (mita (x) x))))`, false)
+	for _, test := range []struct {
+		prefix, want string
+	}{
+		{"kuc", "kucha kuchada"},
+		{"celi", "celi celida"},
+		{"unud", "unudu"},
+		{"dal", "dala"},
+		{"sel", "select"},
+		{"law", "lawa lawada lawyer"},
+		{"lakul", "lakulakucha lakulalawa lakulawa"},
+		{"zzz", ""},
+	} {
+		got := strings.Join(c.Complete(test.prefix), " ")
+		if got != test.want {
+			t.Errorf("Complete(%q) = %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
@@ -1,5 +1,13 @@
 package mita
 
+import "sync/atomic"
+
+// Config sets whether Exprs always print as S-expressions.
+// It is safe to call while other goroutines are evaluating.
 func Config(p bool) {
-	printSExpr = p
+	var v int32
+	if p {
+		v = 1
+	}
+	atomic.StoreInt32(&printSExpr, v)
 }
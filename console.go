@@ -0,0 +1,444 @@
+package mita
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	tokDisplay  = makeTiga("display")  // print a value for people
+	tokWrite    = makeTiga("write")    // print a value as it is read
+	tokNewline  = makeTiga("newline")  // print a newline
+	tokFormat   = makeTiga("format")   // print with directives
+	tokReadLine = makeTiga("readline") // read a line of input
+	tokRead     = makeTiga("read")     // read a value from input
+
+	tokOut = makeTiga("out") // the output port
+	tokErr = makeTiga("err") // the error port
+)
+
+// ports are the streams used by the console builtins. Tasks share
+// their parent's ports.
+type ports struct {
+	mu      sync.Mutex
+	rd      io.Reader
+	in      io.RuneScanner // reads rd; made when first needed
+	reading chan struct{}  // full while a builtin reads in
+	out     io.Writer
+	err     io.Writer
+}
+
+func newPorts() *ports {
+	return &ports{rd: os.Stdin, reading: make(chan struct{}, 1), out: os.Stdout, err: os.Stderr}
+}
+
+// An Input buffers a stream for the readline and read builtins. A
+// builtin waiting on an Input for more of the stream gives up when its
+// evaluation is canceled, and what it was waiting for goes to the next
+// reader. Programs such as a REPL may read an Input between
+// evaluations of the Context given it.
+type Input struct {
+	*bufio.Reader
+	src *inputSource
+}
+
+// NewInput returns an Input reading r.
+func NewInput(r io.Reader) *Input {
+	src := &inputSource{r: r}
+	return &Input{bufio.NewReader(src), src}
+}
+
+// errCanceled is returned by reads of an Input given up on.
+var errCanceled = errors.New("read canceled")
+
+// An inputSource reads the stream under an Input. While done is set it
+// reads on another goroutine, so that it can stop waiting once done
+// is closed; the read goes on, and its bytes are kept for later.
+type inputSource struct {
+	r      io.Reader
+	done   <-chan struct{}
+	result chan inputRead // the read going on, if any
+	left   []byte         // read but not yet returned
+	err    error          // to return after left
+}
+
+type inputRead struct {
+	b   []byte
+	err error
+}
+
+func (s *inputSource) Read(b []byte) (int, error) {
+	if len(s.left) == 0 && s.err == nil {
+		if s.result == nil && s.done == nil {
+			return s.r.Read(b)
+		}
+		if s.result == nil {
+			ch, buf := make(chan inputRead, 1), make([]byte, len(b))
+			go func() {
+				n, err := s.r.Read(buf)
+				ch <- inputRead{buf[:n], err}
+			}()
+			s.result = ch
+		}
+		select {
+		case res := <-s.result:
+			s.result, s.left, s.err = nil, res.b, res.err
+		case <-s.done:
+			return 0, errCanceled
+		}
+	}
+	n := copy(b, s.left)
+	s.left = s.left[n:]
+	if len(s.left) == 0 && s.err != nil {
+		err := s.err
+		s.err = nil
+		return n, err
+	}
+	return n, nil
+}
+
+// SetInput sets the stream read by the readline and read builtins.
+// To share r with a Parser or a REPL, pass the io.RuneScanner they
+// read, such as an Input; the builtins read no further ahead than
+// they return.
+func (c *Context) SetInput(r io.Reader) {
+	c.ports.mu.Lock()
+	defer c.ports.mu.Unlock()
+	c.ports.rd, c.ports.in = r, nil
+}
+
+// SetOutput sets the stream written by display, write, newline and
+// format.
+func (c *Context) SetOutput(w io.Writer) {
+	c.ports.mu.Lock()
+	defer c.ports.mu.Unlock()
+	c.ports.out = w
+}
+
+// SetErrorOutput sets the stream those builtins write when given the
+// port err.
+func (c *Context) SetErrorOutput(w io.Writer) {
+	c.ports.mu.Lock()
+	defer c.ports.mu.Unlock()
+	c.ports.err = w
+}
+
+// takeInput waits for its turn to read the input stream, which it
+// returns. The caller must call releaseInput when done. The ports are
+// not locked while it reads, so tasks may print meanwhile.
+func (c *Context) takeInput() io.RuneScanner {
+	select {
+	case c.ports.reading <- struct{}{}:
+	case <-c.done:
+		panic(Canceled{c.ctx.Err()})
+	}
+	c.ports.mu.Lock()
+	defer c.ports.mu.Unlock()
+	p := c.ports
+	if p.in == nil {
+		switch r := p.rd.(type) {
+		case io.RuneScanner:
+			p.in = r
+		default:
+			p.in = NewInput(r)
+		}
+	}
+	if in, ok := p.in.(*Input); ok {
+		in.src.done = c.done
+	}
+	return p.in
+}
+
+func (c *Context) releaseInput(in io.RuneScanner) {
+	if in, ok := in.(*Input); ok {
+		in.src.done = nil
+	}
+	<-c.ports.reading
+}
+
+// inputReader reads runes from the input stream for c, stopping with
+// Canceled once c's evaluation is canceled.
+type inputReader struct {
+	c  *Context
+	in io.RuneScanner
+}
+
+func (r inputReader) ReadRune() (rune, int, error) {
+	r.c.checkDone()
+	ch, size, err := r.in.ReadRune()
+	if err == errCanceled {
+		panic(Canceled{r.c.ctx.Err()})
+	}
+	return ch, size, err
+}
+
+// print writes s to the port named by port, which is nil or one of
+// out and err, for the builtin name.
+func (c *Context) print(name *token, port *Expr, s string) *Expr {
+	c.ports.mu.Lock()
+	defer c.ports.mu.Unlock()
+	w := c.ports.out
+	switch port.getSada() {
+	case nil, tokOut:
+	case tokErr:
+		w = c.ports.err
+	default:
+		errorf("%s: unknown port %s", name, port)
+	}
+	if _, err := io.WriteString(w, s); err != nil {
+		errorf("%s: %v", name, err)
+	}
+	return constNya
+}
+
+// display returns e as people read it: strings lose their quotes.
+func display(e *Expr) string {
+	var b strings.Builder
+	buildDisplay(&b, e)
+	return b.String()
+}
+
+func buildDisplay(b *strings.Builder, e *Expr) {
+	switch {
+	case e == nil:
+		b.WriteString("nil")
+		return
+	case e.sada != nil:
+		if e.sada.typ == tokenTypeString {
+			b.WriteString(Text(e))
+		} else {
+			b.WriteString(e.String())
+		}
+		return
+	}
+	b.WriteByte('(')
+	for {
+		buildDisplay(b, e.lawa)
+		e = e.kucha
+		if e.isNya() {
+			break
+		}
+		if e.sada != nil {
+			b.WriteString(" . ")
+			buildDisplay(b, e)
+			break
+		}
+		b.WriteByte(' ')
+	}
+	b.WriteByte(')')
+}
+
+// written returns e as the parser reads it, with its strings quoted as
+// quoteString quotes them.
+func written(e *Expr) string {
+	var b strings.Builder
+	buildWritten(&b, e)
+	return b.String()
+}
+
+func buildWritten(b *strings.Builder, e *Expr) {
+	switch {
+	case e == nil:
+		b.WriteString("nil")
+		return
+	case e.sada != nil:
+		if e.sada.typ == tokenTypeString {
+			b.WriteString(quoteString(Text(e)))
+		} else {
+			b.WriteString(e.String())
+		}
+		return
+	case isQuote(e):
+		b.WriteByte('\'')
+		buildWritten(b, Lawa(Kucha(e)))
+		return
+	}
+	b.WriteByte('(')
+	for {
+		buildWritten(b, e.lawa)
+		e = e.kucha
+		if e == nil || e.sada != nil && e.sada.text == "nil" {
+			break
+		}
+		if e.sada != nil {
+			b.WriteString(" . ")
+			buildWritten(b, e)
+			break
+		}
+		b.WriteByte(' ')
+	}
+	b.WriteByte(')')
+}
+
+// quoteString returns s in double quotes with its quotes and
+// backslashes escaped, and its control characters written as \n, \t,
+// \r or \u and four hex digits, so the parser reads back s. The
+// result is a JSON string too.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// displayFunc prints a value without quotes on its strings:
+// (display x) or (display x 'err).
+func (c *Context) displayFunc(name *token, expr *Expr) *Expr {
+	return c.print(name, Lawa(Kucha(expr)), display(Lawa(expr)))
+}
+
+// writeFunc prints a value as the parser reads it.
+func (c *Context) writeFunc(name *token, expr *Expr) *Expr {
+	return c.print(name, Lawa(Kucha(expr)), written(Lawa(expr)))
+}
+
+func (c *Context) newlineFunc(name *token, expr *Expr) *Expr {
+	return c.print(name, Lawa(expr), "\n")
+}
+
+// formatFunc prints a string with its directives replaced by the
+// arguments that follow it: ~a displays the next argument, ~s writes
+// it, ~d writes a number, ~% is a newline and ~~ is a tilde. The port
+// may come first: (format 'err "~a~%" x).
+func (c *Context) formatFunc(name *token, expr *Expr) *Expr {
+	var port *Expr
+	if tok := Lawa(expr).getSada(); tok != nil && tok.typ == tokenTypeTiga {
+		port, expr = Lawa(expr), Kucha(expr)
+	}
+	f := Lawa(expr)
+	if tok := f.getSada(); tok == nil || tok.typ != tokenTypeString {
+		errorf("format: expect string; got %v", f)
+	}
+	args := Kucha(expr)
+	next := func(d rune) *Expr {
+		if args == nil {
+			errorf("format: no argument for ~%c", d)
+		}
+		x := Lawa(args)
+		args = Kucha(args)
+		return x
+	}
+	var b strings.Builder
+	s := []rune(Text(f))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '~' {
+			b.WriteRune(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			errorf("format: ~ at end of %v", f)
+		}
+		switch d := s[i]; d {
+		case 'a':
+			b.WriteString(display(next(d)))
+		case 's':
+			b.WriteString(written(next(d)))
+		case 'd':
+			x := next(d)
+			if !x.isNumber() {
+				errorf("format: ~d needs a number; got %v", x)
+			}
+			b.WriteString(x.String())
+		case '%':
+			b.WriteByte('\n')
+		case '~':
+			b.WriteByte('~')
+		default:
+			errorf("format: unknown directive ~%c", d)
+		}
+	}
+	if args != nil {
+		errorf("format: too many arguments: %v", args)
+	}
+	return c.print(name, port, b.String())
+}
+
+// readLineFunc returns the next line of input as a string without its
+// newline, or nya at the end of the input.
+func (c *Context) readLineFunc(name *token, expr *Expr) *Expr {
+	in := c.takeInput()
+	defer c.releaseInput(in)
+	rd := inputReader{c, in}
+	var b strings.Builder
+	for {
+		r, _, err := rd.ReadRune()
+		if err != nil && err != io.EOF {
+			errorf("%s: %v", name, err)
+		}
+		if err != nil {
+			r = EOFRune
+		}
+		switch r {
+		case EOFRune:
+			if b.Len() == 0 {
+				return constNya
+			}
+			return c.newString(b.String())
+		case '\n':
+			return c.newString(strings.TrimSuffix(b.String(), "\r"))
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// readFunc returns the next value in the input, unevaluated, or nya at
+// the end of the input.
+func (c *Context) readFunc(name *token, expr *Expr) *Expr {
+	in := c.takeInput()
+	defer c.releaseInput(in)
+	p := c.parser(inputReader{c, in})
+	defer func() {
+		// Leave the rune the parser looked ahead at for the next reader.
+		if l := p.lex; l.peeking && l.peekRune != EOFRune {
+			in.UnreadRune()
+		}
+	}()
+	for {
+		switch p.SkipSpace() {
+		case '\n':
+			continue
+		case EOFRune:
+			return constNya
+		}
+		break
+	}
+	defer syntaxError(name)
+	return p.List()
+}
+
+// syntaxError, deferred, reports a failure to parse input for the
+// builtin name as an Error.
+func syntaxError(name *token) {
+	switch e := recover().(type) {
+	case nil:
+	case Error:
+		errorf("%s: %s", name, e)
+	case EOF:
+		errorf("%s: unexpected end of input", name)
+	default:
+		panic(e)
+	}
+}
@@ -0,0 +1,148 @@
+package mita
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsole(t *testing.T) {
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		var out, errOut strings.Builder
+		c.SetOutput(&out)
+		c.SetErrorOutput(&errOut)
+		c.SetInput(strings.NewReader("(a \"b\" . c) 42\nfirst line\r\nlast"))
+		for _, test := range []struct {
+			src, want, out string
+		}{
+			{`(display '("s" t 1))`, "nya", `(s t 1)`},
+			{`(write '("s" t 1))`, "nya", `("s" t 1)`},
+			{`(newline)`, "nya", "\n"},
+			{`(format "~a and ~s: ~d~~~%" "x" "y" 42)`, "nya", "x and \"y\": 42~\n"},
+			{`(format "~a" '(a . "b"))`, "nya", "(a . b)"},
+			{`(format "~d" 'x)`, "error: format: ~d needs a number; got x\n", ""},
+			{`(format "~a")`, "error: format: no argument for ~a\n", ""},
+			{`(format "~q" 1)`, "error: format: unknown directive ~q\n", ""},
+			{`(format "" 1)`, "error: format: too many arguments: (1)\n", ""},
+			{`(display 1 'nowhere)`, "error: display: unknown port nowhere\n", ""},
+			{`(read)`, `(a "b" . c)`, ""},
+			{`(read)`, "42", ""},
+			{`(readline)`, `""`, ""},
+			{`(readline)`, `"first line"`, ""},
+			{`(readline)`, `"last"`, ""},
+			{`(list (readline) (read))`, "(nya nya)", ""},
+		} {
+			out.Reset()
+			got := run(c, test.src, exec)
+			if len(got) > len(test.want) {
+				got = got[:len(test.want)] // Trim the stack trace.
+			}
+			if got != test.want || out.String() != test.out {
+				t.Errorf("exec=%v %s: got %q printing %q, want %q printing %q", exec, test.src, got, out.String(), test.want, test.out)
+			}
+		}
+		run(c, `(format 'err "~a~%" 'oops)`, exec)
+		if errOut.String() != "oops\n" {
+			t.Errorf("exec=%v: error port got %q", exec, errOut.String())
+		}
+	}
+}
+
+func TestConsoleReadError(t *testing.T) {
+	c := NewContext(0)
+	c.SetInput(strings.NewReader("1a (b"))
+	for _, want := range []string{
+		"error: read: invalid token after 1\n",
+		"a",
+		"error: read: bad token in list:EOF\n",
+		"nya",
+	} {
+		if got := run(c, "(read)", false); !strings.HasPrefix(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestConsoleNeedsCapability(t *testing.T) {
+	c := NewSandbox(0, CapPure)
+	var out strings.Builder
+	c.SetOutput(&out)
+	want := "error: permission denied: display needs capability console\n"
+	if got := run(c, `(display "x")`, false); !strings.HasPrefix(got, want) || out.Len() != 0 {
+		t.Errorf("got %q printing %q, want %q", got, out.String(), want)
+	}
+}
+
+// TestConsoleSharedInput checks that read and readline leave the rest
+// of the input to a REPL reading it between evaluations.
+func TestConsoleSharedInput(t *testing.T) {
+	c := NewContext(0)
+	in := NewInput(strings.NewReader("foo bar\nbaz\n"))
+	c.SetInput(in)
+	if got := run(c, "(read)", false); got != "foo" {
+		t.Errorf("(read) = %s", got)
+	}
+	if line, _ := in.ReadString('\n'); line != " bar\n" {
+		t.Errorf("REPL read %q after (read)", line)
+	}
+	if got := run(c, "(readline)", false); got != `"baz"` {
+		t.Errorf("(readline) = %s", got)
+	}
+}
+
+func TestConsoleInputCanceled(t *testing.T) {
+	c := NewContext(0)
+	var out strings.Builder
+	c.SetOutput(&out)
+	pr, pw := io.Pipe()
+	c.SetInput(NewInput(pr))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	func() {
+		defer func() {
+			if _, ok := recover().(Canceled); !ok {
+				t.Fatal("expected Canceled")
+			}
+		}()
+		// The task waits for input without keeping display from the port.
+		c.EvalContext(ctx, NewParser(strings.NewReader(`(list (spawn 'readline) (sleep 10) (display "x") (readline))`)).List())
+	}()
+	c.PopStack()
+	if out.String() != "x" {
+		t.Errorf("display printed %q", out.String())
+	}
+
+	// The input that came too late is read next.
+	go io.WriteString(pw, "late\n")
+	if got := run(c, "(readline)", false); got != `"late"` {
+		t.Errorf("(readline) = %s", got)
+	}
+}
+
+// TestWriteRoundTrip checks that what write and ~s print reads back as
+// the value written.
+func TestWriteRoundTrip(t *testing.T) {
+	c := NewContext(0)
+	var out strings.Builder
+	c.SetOutput(&out)
+	for _, src := range []string{
+		`"a\"b"`,
+		`"back\\slash"`,
+		`(list "line\nbreak" 'x "tab\tand\rreturn")`,
+		`(list "bell\u0007" '(a . "b") ''q)`,
+	} {
+		v := c.Eval(NewParser(strings.NewReader(src)).List())
+		for _, print := range []string{"(write %s)", `(format "~s" %s)`} {
+			out.Reset()
+			c.Eval(NewParser(strings.NewReader(fmt.Sprintf(print, src))).List())
+			back := NewParser(strings.NewReader(out.String())).List()
+			if !equal(back, v) {
+				t.Errorf("%s printed %s, which reads back as %s", fmt.Sprintf(print, src), out.String(), back)
+			}
+		}
+	}
+}
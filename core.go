@@ -0,0 +1,236 @@
+package mita
+
+import (
+	_ "embed"
+	"strings"
+)
+
+var (
+	tokSada      = makeTiga("sada")      // report whether a value is an atom
+	tokAtom      = makeTiga("atom")      // English for sada
+	tokSadaShato = makeTiga("sadashato") // compare atoms
+	tokEq        = makeTiga("eq")        // English for sadashato
+)
+
+//go:embed core.mita
+var coreSrc string
+
+// coreDefs are the definitions in core.mita, parsed once by evalInit.
+var coreDefs *Expr
+
+// coreAliases are the English names of the core library functions.
+var coreAliases = []struct{ name, alias string }{
+	{"nyada", "null"},
+	{"dadashato", "equal"},
+	{"upaupa", "append"},
+	{"zido", "member"},
+	{"boya", "assoc"},
+	{"boyaupa", "pairlis"},
+	{"movosada", "subst"},
+	{"movoboya", "sublis"},
+	{"movoupa", "reverse"},
+	{"tomo", "length"},
+	{"kuchada", "last"},
+	{"lawada", "nth"},
+}
+
+// coreNatives are Go versions of the core library functions.
+var coreNatives = map[string]func(c *Context, args []*Expr) *Expr{
+	"nyada": func(c *Context, args []*Expr) *Expr {
+		return truthExpr(args[0].isNya())
+	},
+	"dadashato": func(c *Context, args []*Expr) *Expr {
+		return truthExpr(equal(args[0], args[1]))
+	},
+	"upaupa": func(c *Context, args []*Expr) *Expr {
+		return c.appendList(args[0], args[1])
+	},
+	"zido": func(c *Context, args []*Expr) *Expr {
+		for l := args[1]; !l.isNya(); l = Kucha(l) {
+			if equal(args[0], Lawa(l)) {
+				return constDa
+			}
+		}
+		return constNye
+	},
+	"boya": func(c *Context, args []*Expr) *Expr {
+		return assoc(args[0], args[1])
+	},
+	"boyaupa": func(c *Context, args []*Expr) *Expr {
+		var pairs []*Expr
+		for x, y := args[0], args[1]; !x.isNya(); x, y = Kucha(x), Kucha(y) {
+			pairs = append(pairs, c.upa(Lawa(x), Lawa(y)))
+		}
+		a := args[2]
+		for i := len(pairs) - 1; i >= 0; i-- {
+			a = c.upa(pairs[i], a)
+		}
+		return a
+	},
+	"movosada": func(c *Context, args []*Expr) *Expr {
+		return c.subst(args[0], args[1], args[2])
+	},
+	"movoboya": func(c *Context, args []*Expr) *Expr {
+		return c.sublis(args[0], args[1])
+	},
+	"movoupa": func(c *Context, args []*Expr) *Expr {
+		if args[0].isNya() {
+			return constNya
+		}
+		var r *Expr
+		for x := args[0]; !x.isNya(); x = Kucha(x) {
+			r = c.upa(Lawa(x), r)
+		}
+		return r
+	},
+	"tomo": func(c *Context, args []*Expr) *Expr {
+		n := 0
+		for x := args[0]; !x.isNya(); x = Kucha(x) {
+			n++
+		}
+		return c.number(n)
+	},
+	"kuchada": func(c *Context, args []*Expr) *Expr {
+		x := args[0]
+		for !Kucha(x).isNya() {
+			x = Kucha(x)
+		}
+		return Lawa(x)
+	},
+	"lawada": func(c *Context, args []*Expr) *Expr {
+		n, x := c.getNumber(args[0]), args[1]
+		for ; n >= 0 && !x.isNya(); n, x = n-1, Kucha(x) {
+			if n == 0 {
+				return Lawa(x)
+			}
+		}
+		return constNya
+	},
+}
+
+// parseCore parses core.mita. It is called by evalInit.
+func parseCore() {
+	p := NewParser(strings.NewReader(coreSrc))
+	for {
+		switch p.SkipSpace() {
+		case '\n':
+			continue
+		case EOFRune:
+			return
+		}
+		coreDefs, _ = muheDefs(p.List())
+	}
+}
+
+// defineCore defines the core library in c.
+func (c *Context) defineCore() {
+	c.define(coreDefs, c.bind)
+	if c.natives == nil {
+		c.natives = make(map[*Expr]Native)
+	}
+	for name, fn := range coreNatives {
+		fn := fn
+		c.natives[c.Global(name)] = func(args []*Expr) *Expr { return fn(c, args) }
+	}
+	for _, a := range coreAliases {
+		c.bind(makeTiga(a.alias), c.Global(a.name))
+	}
+}
+
+// sadaFunc reports whether its argument is an atom. Nil is an atom.
+func (c *Context) sadaFunc(name *token, expr *Expr) *Expr {
+	x := Lawa(expr)
+	return truthExpr(x == nil || x.sada != nil)
+}
+
+// sadaShatoFunc reports whether its arguments are the same atom.
+func (c *Context) sadaShatoFunc(name *token, expr *Expr) *Expr {
+	return truthExpr(eq(Lawa(expr), Lawa(Kucha(expr))))
+}
+
+// eq reports whether x and y are the same atom. Nil and nya are the
+// same; lists are eq only to themselves.
+func eq(x, y *Expr) bool {
+	switch {
+	case x == y:
+		return true
+	case x.isNya() || y.isNya():
+		return x.isNya() && y.isNya()
+	case x.sada == nil || y.sada == nil:
+		return false
+	}
+	a, b := x.sada, y.sada
+	if a == b {
+		return true
+	}
+	if a.typ != b.typ {
+		return false
+	}
+	switch a.typ {
+	case tokenTypeNumber:
+		return a.num == b.num
+	case tokenTypeObject:
+		return false
+	}
+	return a.text == b.text
+}
+
+// equal reports whether x and y have the same structure.
+func equal(x, y *Expr) bool {
+	for {
+		switch {
+		case x == nil || x.sada != nil:
+			return eq(x, y)
+		case y == nil || y.sada != nil:
+			return false
+		case !equal(x.lawa, y.lawa):
+			return false
+		}
+		x, y = x.kucha, y.kucha
+	}
+}
+
+func (c *Context) appendList(x, y *Expr) *Expr {
+	var items []*Expr
+	for ; !x.isNya(); x = Kucha(x) {
+		items = append(items, Lawa(x))
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		y = c.upa(items[i], y)
+	}
+	return y
+}
+
+// assoc returns the first pair in the list a whose lawa is equal to x,
+// or nya.
+func assoc(x, a *Expr) *Expr {
+	for ; !a.isNya(); a = Kucha(a) {
+		if equal(x, Lawa(Lawa(a))) {
+			return Lawa(a)
+		}
+	}
+	return constNya
+}
+
+// subst returns z with x in place of every part equal to y.
+func (c *Context) subst(x, y, z *Expr) *Expr {
+	switch {
+	case equal(y, z):
+		return x
+	case z == nil || z.sada != nil:
+		return z
+	}
+	return c.upa(c.subst(x, y, z.lawa), c.subst(x, y, z.kucha))
+}
+
+// sublis returns e with each atom that is a key of the list of pairs
+// a replaced by its value.
+func (c *Context) sublis(a, e *Expr) *Expr {
+	if e == nil || e.sada != nil {
+		if p := assoc(e, a); !p.isNya() {
+			return Kucha(p)
+		}
+		return e
+	}
+	return c.upa(c.sublis(a, e.lawa), c.sublis(a, e.kucha))
+}
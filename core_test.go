@@ -0,0 +1,59 @@
+package mita
+
+import "testing"
+
+var coreTests = []struct {
+	src, want string
+}{
+	{"(list (atom 'a) (atom nil) (atom 1) (atom '(a)) (sada \"s\"))", "(da da da nye da)"},
+	{"(list (eq 'a 'a) (eq 1 1) (eq \"s\" \"s\") (eq nil nya) (eq '(a) '(a)) (sadashato 'a 'b))", "(da da da da nye nye)"},
+	{"(list (null nil) (null nya) (null '(a)) (nyada 0))", "(da da nye nye)"},
+	{"(list (equal '(a (b 1) . c) '(a (b 1) . c)) (equal '(a b) '(a c)) (dadashato 'a 'a))", "(da nye da)"},
+	{"(append '(a b) '(c d))", "(a b c d)"},
+	{"(upaupa nil 'x)", "x"},
+	{"(list (member '(b) '(a (b) c)) (member 'd '(a b c)) (zido 'a nil))", "(da nye nye)"},
+	{"(assoc 'b '((a . 1) (b . 2) (b . 3)))", "(b . 2)"},
+	{"(boya 'z '((a . 1)))", "nya"},
+	{"(pairlis '(a b) '(1 2) '((c . 3)))", "((a . 1) (b . 2) (c . 3))"},
+	{"(subst 'x '(b) '(a (b) ((b) . c)))", "(a x (x . c))"},
+	{"(sublis '((a . 1) (b . 2)) '(a (b c) . a))", "(1 (2 c) . 1)"},
+	{"(reverse '(a b (c d)))", "((c d) b a)"},
+	{"(movoupa nil)", "nya"},
+	{"(list (length '(a b c)) (tomo nil))", "(3 0)"},
+	{"(list (last '(a b c)) (kuchada nil))", "(c nil)"},
+	{"(list (nth 0 '(a b)) (nth 1 '(a b)) (nth 2 '(a b)) (lawada -1 '(a b)))", "(a b nya nya)"},
+	{"(nth 'x '(a))", "error: expect number; got x\n"},
+	{"null", "(mita (x) (sadashato x nya))"},
+}
+
+func TestCoreLibrary(t *testing.T) {
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		for _, test := range coreTests {
+			got := run(c, test.src, exec)
+			if len(got) > len(test.want) {
+				got = got[:len(test.want)] // Trim the stack trace.
+			}
+			if got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
+
+// TestCoreNativesMatchDefinitions checks the native core functions
+// against their definitions in core.mita.
+func TestCoreNativesMatchDefinitions(t *testing.T) {
+	native, defined := NewContext(0), NewContext(0)
+	defined.natives = nil
+	for _, test := range coreTests {
+		want, got := run(defined, test.src, false), run(native, test.src, false)
+		if len(want) > len(test.want) {
+			// Errors are raised in different places.
+			want, got = want[:len(test.want)], got[:len(test.want)]
+		}
+		if got != want {
+			t.Errorf("%s: native gave %q, definition gave %q", test.src, got, want)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package mita
 
+import "sync"
+
+var elementaryOnce sync.Once
+
 func evalInit() {
-	if elementary == nil {
+	elementaryOnce.Do(func() {
 		elementary = funcMap{
 			tokUpa:    (*Context).upaFunc,
 			tokMuhe:   (*Context).muheFunc,
@@ -14,14 +18,97 @@ func evalInit() {
 			tokCeliDa: (*Context).celiDaFunc,
 			tokMovoDa: (*Context).movoDaFunc,
 
+			tokSada:      (*Context).sadaFunc,
+			tokAtom:      (*Context).sadaFunc,
+			tokSadaShato: (*Context).sadaShatoFunc,
+			tokEq:        (*Context).sadaShatoFunc,
+
 			tokAba:       (*Context).abaFunc,
 			tokUnta:      (*Context).untaFunc,
 			tokAbaShato:  (*Context).abaShatoFunc,
 			tokUntaShato: (*Context).untaShatoFunc,
 			tokShato:     (*Context).shatoFunc,
 			tokNyeShato:  (*Context).nyeShatoFunc,
+
+			tokNow:    (*Context).nowFunc,
+			tokSleep:  (*Context).sleepFunc,
+			tokGetenv: (*Context).getenvFunc,
+			tokExit:   (*Context).exitFunc,
+
+			tokDisplay:  (*Context).displayFunc,
+			tokWrite:    (*Context).writeFunc,
+			tokNewline:  (*Context).newlineFunc,
+			tokFormat:   (*Context).formatFunc,
+			tokReadLine: (*Context).readLineFunc,
+			tokRead:     (*Context).readFunc,
+
+			tokReadFile:   (*Context).readFileFunc,
+			tokReadLines:  (*Context).readLinesFunc,
+			tokReadForms:  (*Context).readFormsFunc,
+			tokWriteFile:  (*Context).writeFileFunc,
+			tokAppendFile: (*Context).appendFileFunc,
+			tokListDir:    (*Context).listDirFunc,
+			tokExists:     (*Context).existsFunc,
+
+			tokJSONDecode: (*Context).jsonDecodeFunc,
+			tokJSONEncode: (*Context).jsonEncodeFunc,
+
+			tokParse:    (*Context).parseFunc,
+			tokParseAll: (*Context).parseAllFunc,
+			tokEval:     (*Context).evalFunc,
+			tokPretty:   (*Context).prettyFunc,
+
+			tokSpawn: (*Context).spawnFunc,
+			tokAwait: (*Context).awaitFunc,
+			tokChan:  (*Context).chanFunc,
+			tokSend:  (*Context).sendFunc,
+			tokRecv:  (*Context).recvFunc,
+			tokClose: (*Context).closeFunc,
+
+			tokMap:       (*Context).mapFunc,
+			tokFilter:    (*Context).filterFunc,
+			tokFoldl:     (*Context).foldlFunc,
+			tokFoldr:     (*Context).foldrFunc,
+			tokReduce:    (*Context).reduceFunc,
+			tokAny:       (*Context).anyFunc,
+			tokEvery:     (*Context).everyFunc,
+			tokFind:      (*Context).findFunc,
+			tokCount:     (*Context).countFunc,
+			tokPartition: (*Context).partitionFunc,
+			tokSort:      (*Context).sortFunc,
+			tokZip:       (*Context).zipFunc,
+			tokRange:     (*Context).rangeFunc,
+			tokIota:      (*Context).iotaFunc,
+			tokTake:      (*Context).takeFunc,
+			tokDrop:      (*Context).dropFunc,
+			tokFlatten:   (*Context).flattenFunc,
+
+			tokLoad:    (*Context).loadFunc,
+			tokRequire: (*Context).requireFunc,
+
+			tokMemo:      (*Context).memoFunc,
+			tokMemoClear: (*Context).memoClearFunc,
+			tokMemoStat:  (*Context).memoStatFunc,
 		}
-	}
+		for _, tok := range []*token{tokDisplay, tokWrite, tokNewline, tokFormat, tokReadLine, tokRead} {
+			elementaryCaps[tok] = CapConsole
+		}
+		for _, tok := range []*token{tokReadFile, tokReadLines, tokReadForms, tokListDir, tokExists} {
+			elementaryCaps[tok] = CapFSRead
+		}
+		elementaryCaps[tokWriteFile] = CapFSWrite
+		elementaryCaps[tokAppendFile] = CapFSWrite
+		elementaryCaps[tokNow] = CapTime
+		elementaryCaps[tokSleep] = CapTime
+		elementaryCaps[tokGetenv] = CapEnv
+		elementaryCaps[tokExit] = CapProcess
+		for _, tok := range []*token{tokSpawn, tokAwait, tokChan, tokSend, tokRecv, tokClose, tokSelect} {
+			elementaryCaps[tok] = CapTasks
+		}
+		elementaryCaps[tokLoad] = CapFSRead
+		elementaryCaps[tokRequire] = CapFSRead
+		parseCore()
+	})
 }
 
 type (
@@ -29,6 +116,13 @@ type (
 	Error string
 )
 
+// Canceled is the panic value raised when the context.Context passed
+// to EvalContext is done. Err is the context's error.
+type Canceled struct{ Err error }
+
+func (e Canceled) Error() string { return "canceled: " + e.Err.Error() }
+func (e Canceled) Unwrap() error { return e.Err }
+
 func init() {
 	constDa = tigaExpr(tokDa)
 	constNye = tigaExpr(tokNye)
@@ -40,14 +134,14 @@ func (c *Context) applyFunc(name *token, expr *Expr) *Expr {
 }
 
 func (c *Context) upaFunc(name *token, expr *Expr) *Expr {
-	return Upa(Lawa(expr), Lawa(Kucha(expr)))
+	return c.upa(Lawa(expr), Lawa(Kucha(expr)))
 }
 
 func (c *Context) listFunc(name *token, expr *Expr) *Expr {
 	if expr == nil {
 		return nil
 	}
-	return Upa(Lawa(expr), Kucha(expr))
+	return c.upa(Lawa(expr), Kucha(expr))
 }
 
 func (c *Context) lawaFunc(name *token, expr *Expr) *Expr {
@@ -65,9 +159,15 @@ func (c *Context) kuchaFunc(name *token, expr *Expr) *Expr {
 }
 
 func (c *Context) muheFunc(name *token, expr *Expr) *Expr {
+	return c.define(Lawa(expr), c.set)
+}
+
+// define defines the functions in the muhe definitions defs with set,
+// returning their names.
+func (c *Context) define(defs *Expr, set func(*token, *Expr)) *Expr {
 	var names []*Expr
-	for expr = Lawa(expr); expr != nil; expr = Kucha(expr) {
-		fn := Lawa(expr)
+	for ; defs != nil; defs = Kucha(defs) {
+		fn := Lawa(defs)
 		if fn == nil {
 			errorf("empty function in muhe")
 		}
@@ -77,11 +177,11 @@ func (c *Context) muheFunc(name *token, expr *Expr) *Expr {
 			errorf("malformed muhe")
 		}
 		names = append(names, name)
-		c.set(tiga, Lawa(Kucha(fn)))
+		set(tiga, Lawa(Kucha(fn)))
 	}
 	var result *Expr
 	for i := len(names) - 1; i >= 0; i-- {
-		result = Upa(names[i], result)
+		result = c.upa(names[i], result)
 	}
 	return result
 }
@@ -94,18 +194,18 @@ func truthExpr(t bool) *Expr {
 }
 
 func (c *Context) getNumber(expr *Expr) int {
-	if expr.isNya() {
-		return 0
-	}
-	if !expr.isNumber() {
-		errorf("expect number; got %v", expr)
-	}
-	return expr.sada.num
+	return Int(expr)
+}
+
+// number returns a number atom, counting it as an allocation
+// against the Limits.
+func (c *Context) number(n int) *Expr {
+	c.alloc(1)
+	return Number(n)
 }
 
 func (c *Context) mathFunc(expr *Expr, fn func(a, b int) int) *Expr {
-	result := number(fn(c.getNumber(Lawa(expr)), c.getNumber(Lawa(Kucha(expr)))))
-	return tigaExpr(result)
+	return c.number(fn(c.getNumber(Lawa(expr)), c.getNumber(Lawa(Kucha(expr)))))
 }
 
 func aba(a, b int) bool       { return a < b }
@@ -1,40 +1,108 @@
 package mita
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"strings"
 )
 
 type elemFunc func(*Context, *token, *Expr) *Expr
 type funcMap map[*token]elemFunc
-type frame map[*token]*Expr
 
 var (
 	elementary                  funcMap
 	constDa, constNye, constNya *Expr
 )
 
+// cell holds the innermost binding of a symbol in a Context.
+// Variables are dynamically scoped and shallowly bound: a frame that
+// binds a variable saves the old contents of its cell and restores them
+// when it is popped, so a reference costs the same at any depth.
+type cell struct {
+	value *Expr
+	bound bool
+	depth int // index on the scope stack of the binding frame
+}
+
+// saved is the contents of a cell before a frame bound it.
+type saved struct {
+	cell *cell
+	old  cell
+}
+
+// scope is one frame of the execution stack.
 type scope struct {
-	vars frame
-	fn   string
-	args *Expr
+	fn    string
+	args  *Expr
+	slots []*Expr // arguments of a call run by the VM
+	saved []saved // cells to restore when the frame is popped
+
+	inlineSlots [4]*Expr // backing for small slots
+	inlineSaved [4]saved // backing for small saved
 }
 
+// arg0 returns the first argument of the call that pushed s.
+func (s *scope) arg0() *Expr {
+	if len(s.slots) > 0 {
+		return s.slots[0]
+	}
+	return Lawa(s.args)
+}
+
+// Context holds the state of one interpreter. A Context must not be
+// used by more than one goroutine at a time, but separate Contexts
+// may evaluate in parallel.
 type Context struct {
 	scope         []*scope
 	stackDepth    int
 	maxStackDepth int
+
+	ctx   context.Context // set while running EvalContext
+	done  <-chan struct{}
+	tasks []context.CancelFunc // stop the tasks spawned this evaluation
+
+	limits Limits
+	usage  *meter // shared with spawned tasks
+	caps   Capability
+	ports  *ports
+	fsys   fs.FS // used by the file builtins; nil means the OS
+
+	cells map[*token]*cell // bindings of every symbol used
+
+	codes map[*Expr]*Code // compiled mita functions
+	stack []*Expr         // VM operand stack
+	free  []*scope        // VM frames for reuse
+
+	natives map[*Expr]Native     // Go implementations of mita forms
+	memos   map[*Expr]*memoCache // caches of memoised mita forms
+
+	namespaces map[string]*namespace
+	top        unit // names usable at top level
+
+	path     []string        // directories searched by require
+	files    []string        // files being loaded, innermost last
+	required map[string]bool // files loaded by require
+
+	opt *optState // what Optimize has inlined
 }
 
+// NewContext returns a Context with every capability that limits
+// calls to depth; 0 means no limit.
 func NewContext(depth int) *Context {
+	return NewSandbox(depth, CapAll)
+}
+
+// NewSandbox returns a Context that may call only the builtins
+// allowed by caps.
+func NewSandbox(depth int, caps Capability) *Context {
 	evalInit()
-	c := &Context{maxStackDepth: depth}
+	c := &Context{maxStackDepth: depth, caps: caps, ports: newPorts(), usage: new(meter)}
 	c.push(top, nil)
 
-	vars := c.scope[0].vars
-	vars[tokDa] = constDa
-	vars[tokNye] = constNye
-	vars[tokNya] = constNya
+	c.bind(tokDa, constDa)
+	c.bind(tokNye, constNye)
+	c.bind(tokNya, constNya)
 
 	for i, t := range []*token{
 		tokUnu,
@@ -43,18 +111,17 @@ func NewContext(depth int) *Context {
 		tokDuDu,
 		tokMani,
 	} {
-		vars[t] = tigaExpr(&token{typ: tokenTypeNumber,
-			num: i + 1, text: ""})
+		c.bind(t, tigaExpr(&token{typ: tokenTypeNumber,
+			num: i + 1, text: ""}))
 	}
+	c.defineCore()
 	return c
 }
 
 func (c *Context) push(fn string, args *Expr) {
-	c.scope = append(c.scope, &scope{
-		vars: make(frame),
-		fn:   fn,
-		args: args,
-	})
+	sc := &scope{fn: fn, args: args}
+	sc.saved = sc.inlineSaved[:0]
+	c.scope = append(c.scope, sc)
 }
 
 func isLaKucha(s string) bool {
@@ -87,43 +154,48 @@ func isLaKucha(s string) bool {
 
 }
 
+// lakuchaPath returns the steps of the lakucha accessor s in the order
+// they apply, 'l' for lawa and 'k' for kucha, or "" if s is not one:
+// lakucha is "kl". Interning computes it once for each symbol.
+func lakuchaPath(s string) string {
+	if !isLaKucha(s) {
+		return ""
+	}
+	end := len(s) - 4 - len(s)%2 // where its lawa or kucha starts
+	path := []byte{s[end]}
+	for i := end; i > 0; i -= 2 {
+		path = append(path, s[i-2])
+	}
+	return string(path)
+}
+
 func lookupElementary(name *token) elemFunc {
 	if fn, ok := elementary[name]; ok {
 		return fn
 	}
-	if isLaKucha(name.text) {
+	if name.path != "" {
 		return (*Context).lakuchaFunc
 	}
 	return nil
 }
 
 func (c *Context) lakuchaFunc(name *token, expr *Expr) *Expr {
-
-	s := name.text
-	ts := s[len(s)-4-len(s)%2:]
 	expr = Lawa(expr)
-	switch ts {
-	case "kucha":
-		expr = Kucha(expr)
-	case "lawa":
-		expr = Lawa(expr)
-	}
-
-	s = s[:len(s)-len(ts)]
-	for i := len(s); i > 0; i -= 2 {
-		switch s[i-2 : i] {
-		case "la":
+	for i := 0; i < len(name.path); i++ {
+		if name.path[i] == 'l' {
 			expr = Lawa(expr)
-		case "ku":
+		} else {
 			expr = Kucha(expr)
-		default:
-			errorf("unexpected lakucha :%q", s[i-2:i])
 		}
 	}
 	return expr
 }
 
 func (c *Context) pop() {
+	sc := c.scope[len(c.scope)-1]
+	for i := len(sc.saved) - 1; i >= 0; i-- {
+		*sc.saved[i].cell = sc.saved[i].old
+	}
 	c.scope[len(c.scope)-1] = nil
 	c.scope = c.scope[:len(c.scope)-1]
 }
@@ -131,6 +203,7 @@ func (c *Context) pop() {
 // PopStack resets the execution stack.
 func (c *Context) PopStack() {
 	c.stackDepth = 0
+	c.stack = c.stack[:0]
 	for len(c.scope) > 1 {
 		c.pop()
 	}
@@ -140,20 +213,29 @@ func (c *Context) PopStack() {
 // The most recent call appears first. Long stacks are trimmed
 // in the middle.
 func (c *Context) StackTrace() string {
+	return c.stackTrace(true)
+}
+
+// FullStackTrace is like StackTrace but never trims the stack.
+func (c *Context) FullStackTrace() string {
+	return c.stackTrace(false)
+}
+
+func (c *Context) stackTrace(trim bool) string {
 	if c.scope[len(c.scope)-1].fn == top {
 		return ""
 	}
 	var b strings.Builder
 	fmt.Fprintln(&b, "stack:")
 	for i := len(c.scope) - 1; i > 0; i-- {
-		if len(c.scope)-i > 20 && i > 20 { // Skip the middle bits.
+		if trim && len(c.scope)-i > 20 && i > 20 { // Skip the middle bits.
 			i = 20
 			fmt.Fprintln(&b, "\t...")
 			continue
 		}
 		s := c.scope[i]
 		if s.fn != top {
-			fmt.Fprintf(&b, "\t(%s %s)\n", s.fn, Lawa(s.args))
+			fmt.Fprintf(&b, "\t(%s %s)\n", s.fn, s.arg0())
 		}
 	}
 	return b.String()
@@ -161,55 +243,138 @@ func (c *Context) StackTrace() string {
 
 func (c *Context) ResetStack() {
 	c.stackDepth = 0
+	c.stack = c.stack[:0]
 	for len(c.scope) > 1 {
 		c.pop()
 	}
 }
 
-func (c *Context) getScope(tok *token) *scope {
-	var sc *scope
-	// reverse scope finding
-	for i := len(c.scope) - 1; i >= 0; i-- {
-		if _, ok := c.scope[i].vars[tok]; ok {
-			sc = c.scope[i]
-			break
-		}
+func notConst(tok *token) {
+	if tok.typ == tokenTypeConst {
+		errorf("cannot set constant %s", tok)
 	}
-	if sc == nil {
-		return c.scope[len(c.scope)-1]
+}
+
+// cell returns the cell of tok, making it if need be.
+func (c *Context) cell(tok *token) *cell {
+	cl := c.cells[tok]
+	if cl == nil {
+		if c.cells == nil {
+			c.cells = make(map[*token]*cell)
+		}
+		cl = new(cell)
+		c.cells[tok] = cl
 	}
-	return sc
+	return cl
 }
 
-func notConst(tok *token) {
-	if tok.typ == tokenTypeConst {
-		errorf("cannot set constant %s", tok)
+// bind binds tok to expr in the frame on top of the stack.
+func (c *Context) bind(tok *token, expr *Expr) {
+	cl, depth := c.cell(tok), len(c.scope)-1
+	if cl.bound && cl.depth == depth {
+		cl.value = expr
+		return
 	}
+	sc := c.scope[depth]
+	sc.saved = append(sc.saved, saved{cl, *cl})
+	*cl = cell{expr, true, depth}
 }
 
+// set assigns expr to the innermost binding of tok, binding it in the
+// frame on top of the stack if it has none.
 func (c *Context) set(tok *token, expr *Expr) {
 	notConst(tok)
-	c.getScope(tok).vars[tok] = expr
+	if cl := c.cells[tok]; cl != nil && cl.bound {
+		cl.value = expr
+		return
+	}
+	c.bind(tok, expr)
+}
+
+// setGlobal assigns expr to the global binding of tok, even if inner
+// frames hide it.
+func (c *Context) setGlobal(tok *token, expr *Expr) {
+	notConst(tok)
+	cl := c.cell(tok)
+	if cl.bound && cl.depth > 0 {
+		for _, sc := range c.scope[1:] {
+			for i := range sc.saved {
+				if sc.saved[i].cell == cl {
+					sc.saved[i].old = cell{expr, true, 0}
+					return
+				}
+			}
+		}
+	}
+	*cl = cell{expr, true, 0}
 }
 
 func (c *Context) setLocal(tok *token, expr *Expr) {
 	notConst(tok)
-	c.scope[len(c.scope)-1].vars[tok] = expr
+	c.bind(tok, expr)
 }
 
 func (c *Context) get(tok *token) *Expr {
 	switch tok.typ {
-	case tokenTypeNumber, tokenTypeString:
-		return tigaExpr(tok)
+	case tokenTypeNumber, tokenTypeString, tokenTypeObject:
+		return c.tiga(tok)
+	}
+	if cl := c.cells[tok]; cl != nil {
+		return cl.value
+	}
+	return nil
+}
+
+// global returns the binding of tok in the global frame, which inner
+// frames may hide.
+func (c *Context) global(tok *token) (*Expr, bool) {
+	cl := c.cells[tok]
+	if cl == nil {
+		return nil, false
+	}
+	if cl.depth > 0 {
+		// The first frame to bind tok saved its global binding.
+		for _, sc := range c.scope[1:] {
+			for _, s := range sc.saved {
+				if s.cell == cl {
+					return s.old.value, s.old.bound
+				}
+			}
+		}
+	}
+	return cl.value, cl.bound
+}
+
+// globals calls yield with each global variable and its value.
+func (c *Context) globals(yield func(tok *token, v *Expr)) {
+	hidden := make(map[*cell]cell)
+	for _, sc := range c.scope[1:] {
+		for _, s := range sc.saved {
+			if _, ok := hidden[s.cell]; !ok {
+				hidden[s.cell] = s.old
+			}
+		}
+	}
+	for tok, cl := range c.cells {
+		g, ok := hidden[cl]
+		if !ok {
+			g = *cl
+		}
+		if g.bound {
+			yield(tok, g.value)
+		}
 	}
-	return c.getScope(tok).vars[tok]
 }
 
 func (c *Context) apply(name string, fn, x *Expr) *Expr {
 	c.okToCall(name, fn, x)
 	if fn.sada != nil {
 		elem := lookupElementary(fn.sada)
+		if def, ok := c.redefined(fn.sada); ok {
+			return c.apply(name, def, x)
+		}
 		if elem != nil {
+			c.checkCap(fn.sada)
 			return elem(c, fn.sada, x)
 		}
 		if fn.sada.typ != tokenTypeTiga {
@@ -224,25 +389,48 @@ func (c *Context) apply(name string, fn, x *Expr) *Expr {
 		if args.length() != formals.length() {
 			errorf("args mismatch for %s: %s %s", name, formals, args)
 		}
-		c.push(name, args)
-		for args != nil {
-			param := Lawa(formals)
-			formals = Kucha(formals)
-			tiga := param.getSada()
-			if tiga == nil {
-				errorf("no tiga param=%s args=%s formal=%s", param, args, formals)
+		if m, ok := c.memos[fn]; ok {
+			var values []*Expr
+			for ; args != nil; args = Kucha(args) {
+				values = append(values, Lawa(args))
 			}
-			c.setLocal(tiga, Lawa(args))
-			args = Kucha(args)
+			return c.memoCall(m, values, func() *Expr { return c.applyMita(name, fn, x) })
 		}
-		expr := c.eval(Lawa(Kucha(Kucha(fn))))
-		c.pop()
-		return expr
+		return c.applyMita(name, fn, x)
 	}
 	errorf("apply failed:%s", Upa(tigaExpr(makeTiga(name)), x))
 	return x
 }
 
+// applyMita applies the mita form fn to the arguments x, which
+// match its parameters in number.
+func (c *Context) applyMita(name string, fn, x *Expr) *Expr {
+	args, formals := x, Lawa(Kucha(fn))
+	c.push(name, args)
+	if native, ok := c.natives[fn]; ok {
+		var values []*Expr
+		for ; args != nil; args = Kucha(args) {
+			values = append(values, Lawa(args))
+		}
+		v := native(values)
+		c.pop()
+		return v
+	}
+	for args != nil {
+		param := Lawa(formals)
+		formals = Kucha(formals)
+		tiga := param.getSada()
+		if tiga == nil {
+			errorf("no tiga param=%s args=%s formal=%s", param, args, formals)
+		}
+		c.setLocal(tiga, Lawa(args))
+		args = Kucha(args)
+	}
+	expr := c.eval(Lawa(Kucha(Kucha(fn))))
+	c.pop()
+	return expr
+}
+
 const top = "<top>"
 
 func (e *Expr) getSada() *token {
@@ -252,9 +440,17 @@ func (e *Expr) getSada() *token {
 	return nil
 }
 
+// Eval evaluates expr at top level. Resource usage is counted afresh
+// for each call; see SetLimits.
 func (c *Context) Eval(expr *Expr) *Expr {
+	c.usage = new(meter)
+	defer c.stopTasks()
+	expr, done := c.topLevel(&c.top, expr)
+	if done {
+		return expr
+	}
 	if t := expr.getSada(); t != nil {
-		if lookupElementary(t) != nil {
+		if _, ok := c.redefined(t); !ok && lookupElementary(t) != nil {
 			errorf("%s is elementary", t)
 		}
 		return c.get(t)
@@ -266,7 +462,35 @@ func (c *Context) Eval(expr *Expr) *Expr {
 	return c.apply(top, lambda, nil)
 }
 
+// EvalContext is like Eval but stops with a Canceled panic once ctx is
+// done. The Context stays usable; call PopStack after recovering, as
+// for any other Error.
+func (c *Context) EvalContext(ctx context.Context, expr *Expr) *Expr {
+	return c.withContext(ctx, c.Eval, expr)
+}
+
+func (c *Context) withContext(ctx context.Context, eval func(*Expr) *Expr, expr *Expr) *Expr {
+	outer, done := c.ctx, c.done
+	c.ctx, c.done = ctx, ctx.Done()
+	defer func() { c.ctx, c.done = outer, done }()
+	c.checkDone()
+	return eval(expr)
+}
+
+// checkDone panics with Canceled if the running EvalContext is done.
+func (c *Context) checkDone() {
+	if c.done == nil {
+		return
+	}
+	select {
+	case <-c.done:
+		panic(Canceled{c.ctx.Err()})
+	default:
+	}
+}
+
 func (c *Context) okToCall(name string, fn, x *Expr) {
+	c.checkDone()
 	if fn == nil {
 		errorf("undefined: %s", Upa(tigaExpr(makeToken(tokenTypeTiga, name)), x))
 	}
@@ -292,6 +516,10 @@ func (c *Context) eval(e *Expr) *Expr {
 			return Lawa(Kucha(e))
 		case tokDala:
 			return c.evalCondition(Kucha(e))
+		case tokSelect:
+			return c.evalSelect(Kucha(e))
+		case tokImport, tokNamespace:
+			errorf("%s must be at top level", tiga)
 		}
 		l := c.evalList(Kucha(e))
 		r := c.apply(tiga.text, Lawa(e), l)
@@ -306,6 +534,7 @@ func (c *Context) evalCondition(x *Expr) *Expr {
 	if x == nil {
 		errorf("no true case in cond")
 	}
+	c.checkDone()
 	if c.eval(Lawa(Lawa(x))).isTrue() {
 		return c.eval(Lawa(Kucha(Lawa(x))))
 	}
@@ -316,7 +545,7 @@ func (c *Context) evalList(m *Expr) *Expr {
 	if m == nil {
 		return nil
 	}
-	return Upa(c.eval(Lawa(m)), c.evalList(Kucha(m)))
+	return c.upa(c.eval(Lawa(m)), c.evalList(Kucha(m)))
 }
 
 func Lawa(e *Expr) *Expr {
@@ -1,8 +1,14 @@
 package mita
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestIsLawaKucha(t *testing.T) {
@@ -25,6 +31,32 @@ func TestIsLawaKucha(t *testing.T) {
 	}
 }
 
+// TestLakuchaPath checks that an accessor's steps are worked out when
+// its symbol is interned, and that calls follow them without reading
+// the name again.
+func TestLakuchaPath(t *testing.T) {
+	for _, tc := range []struct{ name, path string }{
+		{"lalawa", "ll"},
+		{"lakucha", "kl"},
+		{"kulakucha", "klk"},
+		{"kulakuwa", ""},
+	} {
+		if got := makeTiga(tc.name).path; got != tc.path {
+			t.Errorf("%s: path %q, expected %q", tc.name, got, tc.path)
+		}
+	}
+	c := NewContext(0)
+	x := NewParser(strings.NewReader("((a b) c)")).List()
+	tok := &token{typ: tokenTypeTiga, text: "notanaccessor", path: "kl"}
+	fn := lookupElementary(tok)
+	if fn == nil {
+		t.Fatal("no builtin for a token with a path")
+	}
+	if got := fn(c, tok, Upa(x, nil)).String(); got != "c" {
+		t.Errorf("got %s, expected c", got)
+	}
+}
+
 var consTests = []struct {
 	a, b string
 	c    string
@@ -223,3 +255,191 @@ func TestStackTrace(t *testing.T) {
 	c.Eval(p.List())
 	t.Fatal("did not crash")
 }
+
+func TestFullStackTrace(t *testing.T) {
+	c := NewContext(0)
+	run(c, `(muhe ((down (mita (x) (dala ((shato x 0) (movoda 0 0)) (da (down (movo x 1))))))))`, false)
+	defer func() {
+		if _, ok := recover().(Error); !ok {
+			t.Fatal("no error")
+		}
+		trimmed, full := c.StackTrace(), c.FullStackTrace()
+		if !strings.Contains(trimmed, "...") || strings.Count(trimmed, "(down") >= 51 {
+			t.Errorf("trimmed stack:\n%s", trimmed)
+		}
+		if strings.Contains(full, "...") || strings.Count(full, "(down") != 51 {
+			t.Errorf("full stack:\n%s", full)
+		}
+	}()
+	c.Eval(NewParser(strings.NewReader(`(down 50)`)).List())
+	t.Fatal("did not crash")
+}
+
+func TestGlobals(t *testing.T) {
+	c := NewContext(0)
+	run(c, `(muhe ((zzsq (mita (x) (celi x x)))))`, false)
+	names := c.Globals()
+	if !sort.StringsAreSorted(names) {
+		t.Error("globals not sorted")
+	}
+	if i := sort.SearchStrings(names, "zzsq"); i == len(names) || names[i] != "zzsq" {
+		t.Errorf("zzsq not among %q", names)
+	}
+	if !IsBuiltin("lawa") || IsBuiltin("zzsq") {
+		t.Error("IsBuiltin wrong")
+	}
+}
+
+func TestParallelContexts(t *testing.T) {
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Config(i%2 == 0)
+			c := NewContext(0)
+			// Each goroutine interns fresh symbols to exercise the shared table.
+			fn := fmt.Sprintf(`(muhe((yafib%d (mita (si%d)
+				(dala ((aba si%d du) si%d)
+					(da (celi (yafib%d (movo si%d du)) (yafib%d (movo si%d unu)))))))))`,
+				i, i, i, i, i, i, i, i)
+			c.Eval(NewParser(strings.NewReader(fn)).List())
+			in := fmt.Sprintf("(yafib%d 15)", i)
+			got := c.Eval(NewParser(strings.NewReader(in)).List()).String()
+			if got != "610" {
+				errs <- fmt.Sprintf("%s = %s, expected 610", in, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+	Config(false)
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestEvalContextCancel(t *testing.T) {
+	c := NewContext(0)
+	c.Eval(NewParser(strings.NewReader(examples[0].fn)).List())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	func() {
+		defer func() {
+			e, ok := recover().(Canceled)
+			if !ok {
+				t.Fatalf("expected Canceled, got %v", e)
+			}
+			if !errors.Is(e, context.DeadlineExceeded) {
+				t.Errorf("Canceled.Err = %v, expected deadline exceeded", e.Err)
+			}
+			if c.StackTrace() == "" {
+				t.Error("no stack trace after cancel")
+			}
+		}()
+		c.EvalContext(ctx, NewParser(strings.NewReader("(yafib 60)")).List())
+		t.Fatal("did not cancel")
+	}()
+	c.PopStack()
+	if got := c.Eval(NewParser(strings.NewReader("(yafib 10)")).List()).String(); got != "55" {
+		t.Errorf("(yafib 10) after cancel = %s, expected 55", got)
+	}
+}
+
+func TestShallowBinding(t *testing.T) {
+	c := NewContext(0)
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"(muhe ((x (mita () 'global)) (get (mita () x)) (bind (mita (x) (get))) (fail (mita (x) (movoda 1 0)))))", "(x get bind fail)"},
+		{"(bind 'local)", "local"},
+		{"x", "(mita nil 'global)"},
+		{"(fail 'local)", "error: div 0\nstack:\n\t(fail local)\n"},
+		{"(get)", "(mita nil 'global)"},
+		{"(await (spawn bind 'task))", "task"},
+		{"(muhe ((forked (mita (x) (await (spawn get))))))", "(forked)"},
+		{"(forked 'local)", "(mita nil 'global)"},
+	} {
+		if got := run(c, test.src, false); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+// BenchmarkLookup looks up a global and a variable bound at the bottom
+// of the stack from the top of stacks of several depths.
+func BenchmarkLookup(b *testing.B) {
+	for _, depth := range []int{1, 100, 10000} {
+		b.Run(fmt.Sprint("depth=", depth), func(b *testing.B) {
+			c := NewContext(0)
+			g, v := makeTiga("g"), makeTiga("v")
+			c.setLocal(g, Number(1))
+			c.push("f", nil)
+			c.setLocal(v, Number(2))
+			for i := 1; i < depth; i++ {
+				c.push("f", nil)
+				c.setLocal(makeTiga(fmt.Sprint("v", i)), Number(i))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.get(g)
+				c.get(v)
+			}
+		})
+	}
+}
+
+// TestNativeCalls checks that natives are called as mita functions
+// are: on the stack, within the depth limit and cancelable.
+func TestNativeCalls(t *testing.T) {
+	c := NewContext(5)
+	lambda := NewParser(strings.NewReader("(mita (x) x)")).List()
+	c.DefineNative("deep", lambda, func(args []*Expr) *Expr {
+		return c.Call("deep", args[0])
+	})
+	func() {
+		defer func() {
+			if e, ok := recover().(Error); !ok || e != "stack too deep" {
+				t.Fatalf("expected stack too deep, got %v", e)
+			}
+			if got := strings.Count(c.FullStackTrace(), "(deep 1)"); got < 2 {
+				t.Errorf("deep calls missing from the stack:\n%s", c.FullStackTrace())
+			}
+		}()
+		c.Eval(NewParser(strings.NewReader("(deep 1)")).List())
+	}()
+	c.PopStack()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.DefineNative("stop", lambda, func(args []*Expr) *Expr {
+		cancel()
+		return c.Call("kucha", args[0])
+	})
+	defer func() {
+		if _, ok := recover().(Canceled); !ok {
+			t.Fatal("expected Canceled")
+		}
+	}()
+	c.EvalContext(ctx, NewParser(strings.NewReader("(stop '(1 2))")).List())
+}
+
+// TestNestedEvalContext checks that an EvalContext called during another
+// leaves the outer one cancelable when it returns.
+func TestNestedEvalContext(t *testing.T) {
+	c := NewContext(0)
+	c.Eval(NewParser(strings.NewReader("(muhe ((id (mita (x) x))))")).List())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.DefineNative("inner", NewParser(strings.NewReader("(mita () nil)")).List(), func(args []*Expr) *Expr {
+		cancel()
+		return c.EvalContext(context.Background(), NewParser(strings.NewReader("(id 1)")).List())
+	})
+	defer func() {
+		if _, ok := recover().(Canceled); !ok {
+			t.Fatal("expected Canceled after the inner evaluation")
+		}
+	}()
+	c.EvalContext(ctx, NewParser(strings.NewReader("(list (inner) (id 2))")).List())
+}
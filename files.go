@@ -0,0 +1,199 @@
+package mita
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	tokReadFile   = makeTiga("readfile")   // a file as a string
+	tokReadLines  = makeTiga("readlines")  // a file as a list of lines
+	tokReadForms  = makeTiga("readforms")  // a file as a list of values
+	tokWriteFile  = makeTiga("writefile")  // replace a file
+	tokAppendFile = makeTiga("appendfile") // add to a file
+	tokListDir    = makeTiga("listdir")    // the names in a directory
+	tokExists     = makeTiga("exists")     // whether a file exists
+)
+
+// WriteFS is a file system the file builtins can write to as well as
+// read. Names are as for the fs.FS it extends.
+type WriteFS interface {
+	fs.FS
+	// WriteFile replaces the contents of the named file, creating it
+	// if need be.
+	WriteFile(name string, data []byte) error
+	// AppendFile adds data to the end of the named file, creating it
+	// if need be.
+	AppendFile(name string, data []byte) error
+}
+
+// SetFS sets the file system used by the file builtins, such as
+// readfile, and by load and require. They can write only if fsys is a
+// WriteFS. By default they use the operating system's files, with
+// names as given.
+func (c *Context) SetFS(fsys fs.FS) {
+	c.fsys = fsys
+}
+
+// DirFS returns a file system for the tree of files rooted at dir.
+// Names are slash-separated and relative to dir, and may not contain
+// .. elements; as with os.DirFS, symbolic links may still lead out of
+// the tree.
+func DirFS(dir string) WriteFS {
+	return dirFS{os.DirFS(dir), dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d dirFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+func (d dirFS) WriteFile(name string, data []byte) error {
+	file, err := d.path("write", name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o666)
+}
+
+func (d dirFS) AppendFile(name string, data []byte) error {
+	file, err := d.path("append", name)
+	if err != nil {
+		return err
+	}
+	return appendFile(file, data)
+}
+
+// osFS is the operating system's file system, taking names as the os
+// package does.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0o666)
+}
+
+func (osFS) AppendFile(name string, data []byte) error {
+	return appendFile(name, data)
+}
+
+func appendFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// fileSystem returns the Context's file system.
+func (c *Context) fileSystem() fs.FS {
+	if c.fsys == nil {
+		return osFS{}
+	}
+	return c.fsys
+}
+
+// writeFS returns the Context's file system for the builtin name,
+// which writes to it.
+func (c *Context) writeFS(name *token) WriteFS {
+	w, ok := c.fileSystem().(WriteFS)
+	if !ok {
+		errorf("%s: file system is read-only", name)
+	}
+	return w
+}
+
+// readFile returns the contents of the file named by the first
+// argument of the builtin name.
+func (c *Context) readFile(name *token, expr *Expr) []byte {
+	data, err := fs.ReadFile(c.fileSystem(), stringArg(name, Lawa(expr)))
+	if err != nil {
+		errorf("%s: %v", name, err)
+	}
+	return data
+}
+
+func (c *Context) readFileFunc(name *token, expr *Expr) *Expr {
+	return c.newString(string(c.readFile(name, expr)))
+}
+
+// readLinesFunc returns the lines of a file as strings, without their
+// newlines.
+func (c *Context) readLinesFunc(name *token, expr *Expr) *Expr {
+	s := string(c.readFile(name, expr))
+	if s == "" {
+		return nil
+	}
+	var lines []*Expr
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		lines = append(lines, c.newString(strings.TrimSuffix(line, "\r")))
+	}
+	return c.list(lines)
+}
+
+// readFormsFunc returns the values written in a file, unevaluated.
+func (c *Context) readFormsFunc(name *token, expr *Expr) *Expr {
+	p := c.parser(bytes.NewReader(c.readFile(name, expr)))
+	return c.list(c.readForms(name, p))
+}
+
+// writeFileFunc replaces a file with a value in display form, so
+// strings are written without quotes: (writefile "out.txt" "text").
+func (c *Context) writeFileFunc(name *token, expr *Expr) *Expr {
+	w := c.writeFS(name)
+	if err := w.WriteFile(stringArg(name, Lawa(expr)), []byte(display(Lawa(Kucha(expr))))); err != nil {
+		errorf("%s: %v", name, err)
+	}
+	return constNya
+}
+
+func (c *Context) appendFileFunc(name *token, expr *Expr) *Expr {
+	w := c.writeFS(name)
+	if err := w.AppendFile(stringArg(name, Lawa(expr)), []byte(display(Lawa(Kucha(expr))))); err != nil {
+		errorf("%s: %v", name, err)
+	}
+	return constNya
+}
+
+// listDirFunc returns the names in a directory, sorted.
+func (c *Context) listDirFunc(name *token, expr *Expr) *Expr {
+	entries, err := fs.ReadDir(c.fileSystem(), stringArg(name, Lawa(expr)))
+	if err != nil {
+		errorf("%s: %v", name, err)
+	}
+	names := make([]*Expr, len(entries))
+	for i, e := range entries {
+		names[i] = c.newString(e.Name())
+	}
+	return c.list(names)
+}
+
+func (c *Context) existsFunc(name *token, expr *Expr) *Expr {
+	_, err := fs.Stat(c.fileSystem(), stringArg(name, Lawa(expr)))
+	switch {
+	case err == nil:
+		return constDa
+	case errors.Is(err, fs.ErrNotExist):
+		return constNye
+	}
+	errorf("%s: %v", name, err)
+	return nil
+}
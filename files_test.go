@@ -0,0 +1,85 @@
+package mita
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileBuiltins(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/a.txt":     {Data: []byte("one\r\ntwo\n\nthree\n")},
+		"data/b.txt":     {Data: []byte("")},
+		"data/sub/c.txt": {Data: []byte("c")},
+		"forms.mita":     {Data: []byte("(a \"b\") ; comment\n\n42 'x\n")},
+		"bad.mita":       {Data: []byte("(a\n")},
+	}
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		c.SetFS(fsys)
+		for _, test := range []struct {
+			src, want string
+		}{
+			{`(readfile "data/sub/c.txt")`, `"c"`},
+			{`(readlines "data/a.txt")`, `("one" "two" "" "three")`},
+			{`(readlines "data/b.txt")`, "nil"},
+			{`(readforms "forms.mita")`, `((a "b") 42 'x)`},
+			{`(readforms "bad.mita")`, "error: readforms: bad token in list:EOF\n"},
+			{`(listdir "data")`, `("a.txt" "b.txt" "sub")`},
+			{`(list (exists "data/a.txt") (exists "data/sub") (exists "data/z.txt"))`, "(da da nye)"},
+			{`(readfile "missing")`, "error: readfile: open missing: file does not exist\n"},
+			{"(readfile 'missing)", "error: readfile: expect string; got missing\n"},
+			{`(writefile "new.txt" "x")`, "error: writefile: file system is read-only\n"},
+		} {
+			got := run(c, test.src, exec)
+			if len(got) > len(test.want) {
+				got = got[:len(test.want)] // Trim the stack trace.
+			}
+			if got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
+
+func TestDirFS(t *testing.T) {
+	dir := t.TempDir()
+	c := NewContext(0)
+	c.SetFS(DirFS(dir))
+	for _, test := range []struct {
+		src, want string
+	}{
+		{`(writefile "out.txt" "hello")`, "nya"},
+		{`(appendfile "out.txt" '(" " "world"))`, "nya"},
+		{`(appendfile "log.txt" 1)`, "nya"},
+		{`(readfile "out.txt")`, `"hello(  world)"`},
+		{`(listdir ".")`, `("log.txt" "out.txt")`},
+		{`(writefile "../escape.txt" "x")`, "error: writefile: write ../escape.txt: invalid argument\n"},
+		{`(readfile "../escape.txt")`, "error: readfile: open ../escape.txt: invalid argument\n"},
+	} {
+		got := run(c, test.src, false)
+		if len(got) > len(test.want) {
+			got = got[:len(test.want)] // Trim the stack trace.
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.src, got, test.want)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "log.txt")); err != nil || string(data) != "1" {
+		t.Errorf("log.txt holds %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); err == nil {
+		t.Errorf("writefile escaped the root")
+	}
+}
+
+func TestFileBuiltinsNeedCapability(t *testing.T) {
+	c := NewSandbox(0, CapFSRead)
+	c.SetFS(DirFS(t.TempDir()))
+	want := "error: permission denied: writefile needs capability fs-write\n"
+	if got := run(c, `(writefile "x" "y")`, false); !strings.HasPrefix(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
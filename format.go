@@ -0,0 +1,29 @@
+package mita
+
+// Format returns the source text src laid out in the canonical way:
+// forms that fit stay on one line and others are broken as Pretty
+// breaks them, with elements separated by single spaces and closing
+// parentheses together at the end of the last line. Comments are kept
+// where they were, on their own lines or after code, and runs of blank
+// lines become one. The result ends with a newline.
+func Format(src []byte) ([]byte, error) {
+	nodes, err := ReadSyntax(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &printer{PrettyOptions: PrettyOptions{Width: 80, Indent: 2}}
+	for i, n := range nodes {
+		switch {
+		case i == 0:
+		case n.Kind == NodeComment && n.Trailing:
+			p.write(" ")
+		default:
+			p.newline(0, n.Blank)
+		}
+		p.print(n)
+	}
+	if len(nodes) > 0 {
+		p.b.WriteByte('\n')
+	}
+	return []byte(p.b.String()), nil
+}
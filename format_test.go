@@ -0,0 +1,101 @@
+package mita
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"(celi   1\n\t2 )", "(celi 1 2)\n"},
+		{"( a . b )  '( c )", "(a . b)\n'(c)\n"},
+		{`(display "a \" b")`, "(display \"a \\\" b\")\n"},
+		{"\n\n(a)\n\n\n\n(b)\n(c)\n\n", "(a)\n\n(b)\n(c)\n"},
+		{"; head\n(a) ; after a\n; before b\n(b)", "; head\n(a) ; after a\n; before b\n(b)\n"},
+		{"(muhe (\n(f (mita (x) x)) ; id\n\n; g\n(g (mita (y)\ny\n)\n)\n)\n)", `
+(muhe (
+  (f (mita (x) x)) ; id
+
+  ; g
+  (g (mita (y) y))))
+`},
+		{"(dala ((aba x 0) ; negative\n 'neg)\n (da 'pos))", `
+(dala ((aba x 0) ; negative
+       'neg)
+      (da 'pos))
+`},
+		{"(f 1) ; done\n'; odd\nx", "(f 1) ; done\n; odd\n'x\n"},
+		{"(f '; odd\n x)", "(f\n   ; odd\n   'x)\n"},
+		{"(f a ; last\n)", "(f a ; last\n   )\n"},
+		{"(" + strings.Repeat("celi ", 20) + ")", `
+(celi celi celi celi celi celi celi celi celi celi celi celi celi celi celi celi
+ celi celi celi celi)
+`},
+		{"", ""},
+	} {
+		got, err := Format([]byte(test.src))
+		if err != nil {
+			t.Errorf("%q: %v", test.src, err)
+			continue
+		}
+		if want := strings.TrimPrefix(test.want, "\n"); string(got) != want {
+			t.Errorf("%q:\ngot\n%s\nwant\n%s", test.src, got, want)
+		}
+		if again, _ := Format(got); string(again) != string(got) {
+			t.Errorf("%q: formatting again gives\n%s", test.src, again)
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"(a\n  (b)", "2:6: unexpected EOF in list"},
+		{"(a))", "1:4: unexpected )"},
+		{"(a '", "1:5: nothing quoted"},
+		{"(a\n  \"open", `2:3: unexpected end of string for "\"open"`},
+	} {
+		_, err := Format([]byte(test.src))
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: got %v, want %s", test.src, err, test.want)
+		}
+	}
+}
+
+// TestFormatKeepsMeaning formats the mita files in the repository and
+// checks that they read as before, comments and all.
+func TestFormatKeepsMeaning(t *testing.T) {
+	read := func(src []byte) string {
+		var b strings.Builder
+		p := NewParser(strings.NewReader(string(src)))
+		for {
+			switch p.SkipSpace() {
+			case '\n':
+				continue
+			case EOFRune:
+				return b.String()
+			}
+			b.WriteString(p.List().SExprString())
+		}
+	}
+	for _, file := range []string{"core.mita", "odomu.mita", "examples/fibonacci.mita"} {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Format(src)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if read(got) != read(src) {
+			t.Errorf("%s: formatting changed the forms", file)
+		}
+		if strings.Count(string(got), ";") != strings.Count(string(src), ";") {
+			t.Errorf("%s: formatting lost comments", file)
+		}
+	}
+}
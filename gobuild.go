@@ -0,0 +1,397 @@
+package mita
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Build writes to w a Go program that runs prog, a sequence of
+// top-level forms, printing the result of each as the mita command
+// does. The program uses the runtime library in package
+// github.com/mitalang/mita/rt.
+//
+// Functions defined by muhe become Go functions. Calls between them,
+// arithmetic, comparisons and the list builtins run natively; anything
+// else is handed to the interpreter in the runtime library. Because
+// Go functions cannot see their callers' variables, a function that
+// refers to a parameter of another function is an error.
+func Build(w io.Writer, prog []*Expr) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			be, ok := e.(buildError)
+			if !ok {
+				panic(e)
+			}
+			err = be
+		}
+	}()
+	evalInit()
+	b := &goBuilder{
+		consts: make(map[string]string),
+		params: make(map[*token]bool),
+		defs:   make(map[*token]int),
+		known:  make(map[*token]*goFunc),
+	}
+	for _, form := range prog {
+		b.scan(form)
+	}
+	for _, form := range prog {
+		b.step(form)
+	}
+	src, ferr := format.Source(b.source())
+	if ferr != nil {
+		return fmt.Errorf("mita build: formatting generated code: %v", ferr)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+type buildError string
+
+func (e buildError) Error() string { return "mita build: " + string(e) }
+
+func buildErrorf(format string, args ...any) {
+	panic(buildError(fmt.Sprintf(format, args...)))
+}
+
+// goFunc is a mita function translated to Go.
+type goFunc struct {
+	name   string // Go name
+	params []*token
+}
+
+type goBuilder struct {
+	decls  strings.Builder   // package-level variables
+	consts map[string]string // Go expression to the variable holding it
+	funcs  strings.Builder
+	nfunc  int
+	steps  []string
+
+	params map[*token]bool    // parameters of every function
+	defs   map[*token]int     // number of definitions of each name
+	known  map[*token]*goFunc // functions defined so far that may be called directly
+}
+
+func (b *goBuilder) source() []byte {
+	var s strings.Builder
+	s.WriteString("// Code generated by mita build; DO NOT EDIT.\n\n")
+	s.WriteString("package main\n\n")
+	s.WriteString("import (\n\t\"github.com/mitalang/mita\"\n\t\"github.com/mitalang/mita/rt\"\n)\n\n")
+	if b.decls.Len() > 0 {
+		fmt.Fprintf(&s, "var (\n%s)\n\n", b.decls.String())
+	}
+	s.WriteString(b.funcs.String())
+	s.WriteString("func main() {\n\trt.Main(\n")
+	for _, step := range b.steps {
+		fmt.Fprintf(&s, "\t\t%s,\n", step)
+	}
+	s.WriteString("\t)\n}\n")
+	return []byte(s.String())
+}
+
+// muheDefs returns the definitions of a muhe form, or false if form is
+// not one.
+func muheDefs(form *Expr) (*Expr, bool) {
+	if Lawa(form).getSada() != tokMuhe {
+		return nil, false
+	}
+	return Lawa(Kucha(form)), true
+}
+
+// scan records the definitions and parameters in form.
+func (b *goBuilder) scan(form *Expr) {
+	defs, ok := muheDefs(form)
+	if !ok {
+		return
+	}
+	for ; defs != nil; defs = Kucha(defs) {
+		name, lambda := b.definition(Lawa(defs))
+		b.defs[name]++
+		for _, p := range b.formals(name, lambda) {
+			b.params[p] = true
+		}
+	}
+}
+
+func (b *goBuilder) definition(def *Expr) (*token, *Expr) {
+	name := Lawa(def).getSada()
+	if name == nil || name.typ != tokenTypeTiga {
+		buildErrorf("malformed muhe: %s", def)
+	}
+	lambda := Lawa(Kucha(def))
+	if Lawa(lambda).getSada() != tokMita {
+		buildErrorf("%s is not a mita form: %s", name, lambda)
+	}
+	return name, lambda
+}
+
+func (b *goBuilder) formals(name *token, lambda *Expr) []*token {
+	var params []*token
+	for formals := Lawa(Kucha(lambda)); formals != nil; formals = Kucha(formals) {
+		p := Lawa(formals).getSada()
+		if p == nil || p.typ != tokenTypeTiga {
+			buildErrorf("bad parameter %s of %s", Lawa(formals), name)
+		}
+		for _, q := range params {
+			if p == q {
+				buildErrorf("repeated parameter %s of %s", p, name)
+			}
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// step translates one top-level form into a step of the program.
+func (b *goBuilder) step(form *Expr) {
+	defs, ok := muheDefs(form)
+	if !ok {
+		if form.getSada() != nil {
+			// Top-level symbols follow Eval's rules.
+			b.steps = append(b.steps, fmt.Sprintf("func() *mita.Expr { return rt.Eval(%s) }", b.constant(datum(form))))
+			return
+		}
+		var s strings.Builder
+		s.WriteString("func() *mita.Expr {\n")
+		b.stmts(&s, form, nil, "\t")
+		s.WriteString("}")
+		b.steps = append(b.steps, s.String())
+		return
+	}
+	type def struct {
+		name   *token
+		lambda *Expr
+		fn     *goFunc
+	}
+	var list []def
+	for ; defs != nil; defs = Kucha(defs) {
+		name, lambda := b.definition(Lawa(defs))
+		b.nfunc++
+		fn := &goFunc{
+			name:   fmt.Sprintf("f%d_%s", b.nfunc, name.text),
+			params: b.formals(name, lambda),
+		}
+		list = append(list, def{name, lambda, fn})
+		if b.defs[name] == 1 {
+			b.known[name] = fn
+		}
+	}
+	var names *Expr
+	for i := len(list) - 1; i >= 0; i-- {
+		names = Upa(tigaExpr(list[i].name), names)
+	}
+	var s strings.Builder
+	s.WriteString("func() *mita.Expr {\n")
+	for _, d := range list {
+		b.function(d.fn, d.lambda)
+		var args []string
+		for i := range d.fn.params {
+			args = append(args, fmt.Sprintf("a[%d]", i))
+		}
+		fmt.Fprintf(&s, "\trt.Define(%q, %s, func(a []*mita.Expr) *mita.Expr { return %s(%s) })\n",
+			d.name.text, b.constant(datum(d.lambda)), d.fn.name, strings.Join(args, ", "))
+	}
+	fmt.Fprintf(&s, "\treturn %s\n}", b.constant(datum(names)))
+	b.steps = append(b.steps, s.String())
+}
+
+func (b *goBuilder) function(fn *goFunc, lambda *Expr) {
+	locals := make(map[*token]string)
+	var params []string
+	for _, p := range fn.params {
+		locals[p] = "v_" + p.text
+		params = append(params, locals[p])
+	}
+	sig := ""
+	if len(params) > 0 {
+		sig = strings.Join(params, ", ") + " *mita.Expr"
+	}
+	fmt.Fprintf(&b.funcs, "func %s(%s) *mita.Expr {\n", fn.name, sig)
+	b.stmts(&b.funcs, Lawa(Kucha(Kucha(lambda))), locals, "\t")
+	b.funcs.WriteString("}\n\n")
+}
+
+// constant returns a package-level variable holding the Go expression x.
+func (b *goBuilder) constant(x string) string {
+	if v, ok := b.consts[x]; ok {
+		return v
+	}
+	v := fmt.Sprintf("k%d", len(b.consts))
+	b.consts[x] = v
+	fmt.Fprintf(&b.decls, "\t%s = %s\n", v, x)
+	return v
+}
+
+// datum returns a Go expression that builds e.
+func datum(e *Expr) string {
+	if e == nil {
+		return "nil"
+	}
+	if tok := e.sada; tok != nil {
+		switch tok.typ {
+		case tokenTypeNumber:
+			return fmt.Sprintf("mita.Number(%d)", tok.num)
+		case tokenTypeString:
+			return fmt.Sprintf("mita.Str(%s)", strconv.Quote(tok.text[1:len(tok.text)-1]))
+		case tokenTypeObject:
+			buildErrorf("cannot build %s", e)
+		}
+		return fmt.Sprintf("mita.Symbol(%q)", tok.text)
+	}
+	return fmt.Sprintf("mita.Upa(%s, %s)", datum(e.lawa), datum(e.kucha))
+}
+
+// stmts writes statements that return the value of e.
+func (b *goBuilder) stmts(s *strings.Builder, e *Expr, locals map[*token]string, indent string) {
+	if Lawa(e).getSada() != tokDala {
+		fmt.Fprintf(s, "%sreturn %s\n", indent, b.expr(e, locals))
+		return
+	}
+	for x := Kucha(e); x != nil; x = Kucha(x) {
+		clause := Lawa(x)
+		test, body := Lawa(clause), Lawa(Kucha(clause))
+		if test.isTrue() {
+			b.stmts(s, body, locals, indent)
+			return
+		}
+		fmt.Fprintf(s, "%sif %s {\n", indent, b.test(test, locals))
+		b.stmts(s, body, locals, indent+"\t")
+		fmt.Fprintf(s, "%s}\n", indent)
+	}
+	fmt.Fprintf(s, "%spanic(mita.Error(\"no true case in cond\"))\n", indent)
+}
+
+// cmpFuncs and mathFuncs name the runtime functions for the binary builtins.
+var (
+	cmpFuncs = map[*token]string{
+		tokAba:       "rt.Aba",
+		tokUnta:      "rt.Unta",
+		tokAbaShato:  "rt.AbaShato",
+		tokUntaShato: "rt.UntaShato",
+		tokShato:     "rt.Shato",
+		tokNyeShato:  "rt.NyeShato",
+	}
+	mathFuncs = map[*token]string{
+		tokCeli:   "rt.Celi",
+		tokMovo:   "rt.Movo",
+		tokCeliDa: "rt.CeliDa",
+		tokMovoDa: "rt.MovoDa",
+	}
+)
+
+// test returns a Go boolean expression for whether e is true.
+func (b *goBuilder) test(e *Expr, locals map[*token]string) string {
+	if fn, ok := cmpFuncs[Lawa(e).getSada()]; ok && Kucha(e).length() == 2 {
+		return fmt.Sprintf("%s(%s)", fn, strings.Join(b.args(e, locals), ", "))
+	}
+	return b.expr(e, locals) + ".IsTrue()"
+}
+
+func (b *goBuilder) args(e *Expr, locals map[*token]string) []string {
+	var args []string
+	for x := Kucha(e); x != nil; x = Kucha(x) {
+		args = append(args, b.expr(Lawa(x), locals))
+	}
+	return args
+}
+
+// expr returns a Go expression for the value of e.
+func (b *goBuilder) expr(e *Expr, locals map[*token]string) string {
+	if e == nil {
+		return "nil"
+	}
+	if tok := e.sada; tok != nil {
+		switch tok.typ {
+		case tokenTypeNumber, tokenTypeString:
+			return b.constant(datum(e))
+		case tokenTypeConst:
+			return b.constant(fmt.Sprintf("rt.Global(%q)", tok.text))
+		}
+		if v, ok := locals[tok]; ok {
+			return v
+		}
+		if b.params[tok] {
+			buildErrorf("%s refers to a parameter of another function", tok)
+		}
+		return fmt.Sprintf("rt.Global(%q)", tok.text)
+	}
+	head := Lawa(e).getSada()
+	switch head {
+	case nil, tokSelect:
+		return b.fallback(e, locals)
+	case tokPlata:
+		return b.constant(datum(Lawa(Kucha(e))))
+	case tokDala:
+		var s strings.Builder
+		s.WriteString("func() *mita.Expr {\n")
+		b.stmts(&s, e, locals, "\t")
+		s.WriteString("}()")
+		return s.String()
+	}
+	args := b.args(e, locals)
+	list := strings.Join(args, ", ")
+	if v, ok := locals[head]; ok {
+		return fmt.Sprintf("rt.Apply(%s)", strings.Join(append([]string{strconv.Quote(head.text), v}, args...), ", "))
+	}
+	if lookupElementary(head) != nil && !(library[head] && b.known[head] != nil) {
+		return b.builtin(head, args)
+	}
+	if fn := b.known[head]; fn != nil && len(fn.params) == len(args) {
+		return fmt.Sprintf("%s(%s)", fn.name, list)
+	}
+	if b.params[head] {
+		buildErrorf("%s refers to a parameter of another function", head)
+	}
+	return fmt.Sprintf("rt.Apply(%s)", strings.Join(append([]string{strconv.Quote(head.text), b.constant(datum(Lawa(e)))}, args...), ", "))
+}
+
+func (b *goBuilder) builtin(head *token, args []string) string {
+	list := strings.Join(args, ", ")
+	switch {
+	case len(args) == 2 && mathFuncs[head] != "":
+		return fmt.Sprintf("%s(%s)", mathFuncs[head], list)
+	case len(args) == 2 && cmpFuncs[head] != "":
+		return fmt.Sprintf("mita.Truth(%s(%s))", cmpFuncs[head], list)
+	case len(args) == 2 && head == tokUpa:
+		return fmt.Sprintf("mita.Upa(%s)", list)
+	case len(args) == 1 && head == tokLawa:
+		return fmt.Sprintf("mita.Lawa(%s)", list)
+	case len(args) == 1 && head == tokKucha:
+		return fmt.Sprintf("mita.Kucha(%s)", list)
+	case len(args) == 1 && head.path != "":
+		x := list
+		for i := 0; i < len(head.path); i++ {
+			if head.path[i] == 'l' {
+				x = fmt.Sprintf("mita.Lawa(%s)", x)
+			} else {
+				x = fmt.Sprintf("mita.Kucha(%s)", x)
+			}
+		}
+		return x
+	}
+	return fmt.Sprintf("rt.Call(%s)", strings.Join(append([]string{strconv.Quote(head.text)}, args...), ", "))
+}
+
+// fallback returns a Go expression that has the interpreter evaluate
+// e, which must not refer to the enclosing function's parameters.
+func (b *goBuilder) fallback(e *Expr, locals map[*token]string) string {
+	var walk func(*Expr)
+	walk = func(x *Expr) {
+		if x == nil {
+			return
+		}
+		if tok := x.sada; tok != nil {
+			if _, ok := locals[tok]; ok {
+				buildErrorf("cannot build %s: it refers to parameter %s", e, tok)
+			}
+			return
+		}
+		walk(x.lawa)
+		walk(x.kucha)
+	}
+	walk(e)
+	return fmt.Sprintf("rt.Eval(%s)", b.constant(datum(e)))
+}
@@ -0,0 +1,125 @@
+package mita
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var buildTests = []string{
+	vmLib[:strings.Index(vmLib, "(outer")] + "))\n" + `
+(yafib 15)
+(second '(1 2 3))
+(twice inc 3)
+(twice 'inc 3)
+(list (plata a) 'b unudu "ohla odomu")
+(upa (lawa '(a b)) (kucha '(c d)))
+(celi (dala ((aba 1 0) 1) (da 2)) 3)
+(apply inc 4)
+(lalakukucha '((1 2) (3 4) ((5 6)) (7 8)))
+inc
+(range 40)
+`,
+	`(muhe ((f (mita (x) (dala ((shato x 0) 'zero))))))
+(f 0)
+(f 1)
+(f 2)`,
+	`(muhe ((count (mita (n) (celi n 1))) (twice (mita (n) (count (count n))))))
+(count 5)
+(twice 5)
+(map 'count '(1 2))`,
+	`(missing 1)`,
+	`(movoda 1 0)`,
+}
+
+// interpret runs the forms in src as the mita command does, returning
+// standard output and standard error.
+func interpret(src string) (stdout, stderr string) {
+	var out bytes.Buffer
+	c := NewContext(0)
+	p := NewParser(strings.NewReader(src))
+	defer func() {
+		if e := recover(); e != nil {
+			stdout, stderr = out.String(), string(e.(Error))+"\n"
+		}
+	}()
+	for {
+		switch p.SkipSpace() {
+		case '\n':
+			continue
+		case EOFRune:
+			return out.String(), ""
+		}
+		out.WriteString(Pretty(c.Eval(p.List()), PrettyOptions{}) + "\n")
+	}
+}
+
+func parseAll(src string) []*Expr {
+	var prog []*Expr
+	p := NewParser(strings.NewReader(src))
+	for {
+		switch p.SkipSpace() {
+		case '\n':
+			continue
+		case EOFRune:
+			return prog
+		}
+		prog = append(prog, p.List())
+	}
+}
+
+func TestBuildMatchesEval(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go run in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command")
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	gomod := "module buildtest\n\ngo 1.18\n\nrequire github.com/mitalang/mita v0.0.0\n\nreplace github.com/mitalang/mita => " + root + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range buildTests {
+		var prog bytes.Buffer
+		if err := Build(&prog, parseAll(src)); err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), prog.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(goTool, "run", ".")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		runErr := cmd.Run()
+		wantOut, wantErr := interpret(src)
+		gotErr := stderr.String()
+		if runErr != nil && wantErr == "" {
+			t.Errorf("%s: %v\n%s\n%s", src, runErr, gotErr, prog.String())
+			continue
+		}
+		// go run reports the exit status after the program's own output.
+		gotErr = strings.TrimSuffix(gotErr, "exit status 1\n")
+		if stdout.String() != wantOut || gotErr != wantErr {
+			t.Errorf("%s:\nbuilt program gave\n%s%s\ninterpreter gave\n%s%s", src, &stdout, gotErr, wantOut, wantErr)
+		}
+	}
+}
+
+func TestBuildDynamicReference(t *testing.T) {
+	prog := parseAll(`(muhe ((outer (mita (x) (inner))) (inner (mita () x))))`)
+	err := Build(new(bytes.Buffer), prog)
+	if err == nil || !strings.Contains(err.Error(), "x refers to a parameter of another function") {
+		t.Errorf("expected dynamic reference error, got %v", err)
+	}
+}
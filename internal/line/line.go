@@ -0,0 +1,529 @@
+// Package line is the line editor of the mita REPL. It reads a line
+// from a terminal in raw mode, with cursor movement, a history that may
+// be kept in a file, reverse search, completion with Tab, and
+// highlighting of the parenthesis matching the one at the cursor.
+package line
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInterrupt is returned by ReadLine when the user types Control-C.
+var ErrInterrupt = errors.New("interrupt")
+
+// maxHistory is the number of lines of history kept.
+const maxHistory = 1000
+
+// maxList is the number of completions listed at most.
+const maxList = 100
+
+// A Completer returns the completions of the text before the cursor,
+// which is at pos in line: each completion replaces line[start:pos].
+// Positions count runes.
+type Completer func(line []rune, pos int) (start int, completions []string)
+
+// An Editor reads lines from a terminal.
+type Editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int // the terminal, put into raw mode while reading; -1 for none
+	history  []string
+	file     string // where the history is kept, if anywhere
+	complete Completer
+}
+
+// New returns an Editor that reads keys from in and draws on out. If fd
+// is not -1, it is the terminal that in reads, which ReadLine puts into
+// raw mode.
+func New(in *bufio.Reader, out io.Writer, fd int) *Editor {
+	return &Editor{in: in, out: out, fd: fd}
+}
+
+// IsTerminal reports whether fd is a terminal the Editor can drive.
+func IsTerminal(fd int) bool {
+	return isTerminal(fd)
+}
+
+// SetHistoryFile loads the history kept in file and appends each line
+// added later to it. The file and its directory are created if need be.
+func (e *Editor) SetHistoryFile(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	e.history = nil
+	for _, l := range strings.Split(string(data), "\n") {
+		if l != "" {
+			e.history = append(e.history, l)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		data := strings.Join(e.history, "\n") + "\n"
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			return err
+		}
+	}
+	e.file = file
+	return nil
+}
+
+// SetCompleter makes Tab complete the text before the cursor with f.
+// Without a Completer, or with nothing to complete, Tab indents.
+func (e *Editor) SetCompleter(f Completer) {
+	e.complete = f
+}
+
+// History returns the lines of history, oldest first.
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// AddHistory adds a line to the history, unless it is empty or the same
+// as the latest. Newlines in l become spaces.
+func (e *Editor) AddHistory(l string) error {
+	l = strings.TrimSpace(strings.ReplaceAll(l, "\n", " "))
+	if l == "" || len(e.history) > 0 && e.history[len(e.history)-1] == l {
+		return nil
+	}
+	e.history = append(e.history, l)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+	if e.file == "" {
+		return nil
+	}
+	f, err := os.OpenFile(e.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, l)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ReadLine shows prompt and returns the line the user edits, without
+// its newline. It returns io.EOF if the user types Control-D on an
+// empty line and ErrInterrupt if they type Control-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd != -1 {
+		restore, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+	s := &state{e: e, prompt: prompt, hist: len(e.history)}
+	return s.run()
+}
+
+// Keys that are not runes.
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+	keyUnknown
+)
+
+func ctrl(r rune) rune { return r & 0x1f }
+
+// readKey returns the next key, decoding escape sequences.
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != '\x1b' {
+		return r, err
+	}
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch r {
+	case 'b':
+		return keyWordLeft, nil
+	case 'f':
+		return keyWordRight, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+	// A control sequence: parameters, then a final byte.
+	var param strings.Builder
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if '0' <= c && c <= '9' || c == ';' {
+			param.WriteRune(c)
+			continue
+		}
+		switch c {
+		case 'A':
+			return keyUp, nil
+		case 'B':
+			return keyDown, nil
+		case 'C':
+			return keyRight, nil
+		case 'D':
+			return keyLeft, nil
+		case 'H':
+			return keyHome, nil
+		case 'F':
+			return keyEnd, nil
+		case '~':
+			switch param.String() {
+			case "1", "7":
+				return keyHome, nil
+			case "4", "8":
+				return keyEnd, nil
+			case "3":
+				return keyDelete, nil
+			}
+		}
+		return keyUnknown, nil
+	}
+}
+
+// state is a line being edited.
+type state struct {
+	e      *Editor
+	prompt string
+	buf    []rune
+	pos    int    // cursor position in buf
+	hist   int    // the history line shown; len(history) for the new line
+	saved  []rune // the new line, while a history line is shown
+	done   bool   // the line is finished
+}
+
+func (s *state) run() (string, error) {
+	s.refresh()
+	for {
+		r, err := s.e.readKey()
+		if err != nil {
+			if err == io.EOF && len(s.buf) > 0 {
+				s.newline()
+				return string(s.buf), nil
+			}
+			return "", err
+		}
+		if r == ctrl('R') {
+			if r, err = s.search(); err != nil {
+				return "", err
+			}
+		}
+		switch done, err := s.key(r); {
+		case err != nil:
+			return "", err
+		case done:
+			return string(s.buf), nil
+		}
+	}
+}
+
+// key acts on the key r, reporting whether the line is finished.
+func (s *state) key(r rune) (bool, error) {
+	switch r {
+	case '\r', '\n':
+		s.pos, s.done = len(s.buf), true
+		s.refresh() // Drop the highlighting.
+		s.newline()
+		return true, nil
+	case ctrl('C'):
+		io.WriteString(s.e.out, "^C")
+		s.newline()
+		return false, ErrInterrupt
+	case ctrl('D'):
+		if len(s.buf) == 0 {
+			s.newline()
+			return false, io.EOF
+		}
+		s.delete(s.pos, s.pos+1)
+	case ctrl('A'), keyHome:
+		s.pos = 0
+	case ctrl('E'), keyEnd:
+		s.pos = len(s.buf)
+	case ctrl('B'), keyLeft:
+		if s.pos > 0 {
+			s.pos--
+		}
+	case ctrl('F'), keyRight:
+		if s.pos < len(s.buf) {
+			s.pos++
+		}
+	case keyWordLeft:
+		s.pos = wordStart(s.buf, s.pos)
+	case keyWordRight:
+		s.pos = wordEnd(s.buf, s.pos)
+	case ctrl('H'), 127:
+		if s.pos > 0 {
+			s.delete(s.pos-1, s.pos)
+		}
+	case keyDelete:
+		s.delete(s.pos, s.pos+1)
+	case ctrl('K'):
+		s.delete(s.pos, len(s.buf))
+	case ctrl('U'):
+		s.delete(0, s.pos)
+	case ctrl('W'):
+		s.delete(wordStart(s.buf, s.pos), s.pos)
+	case ctrl('P'), keyUp:
+		s.showHistory(s.hist - 1)
+	case ctrl('N'), keyDown:
+		s.showHistory(s.hist + 1)
+	case ctrl('L'):
+		io.WriteString(s.e.out, "\x1b[H\x1b[2J")
+	case '\t':
+		s.tab()
+	default:
+		if r < ' ' || r == 127 {
+			return false, nil // Ignore other control keys.
+		}
+		s.insert(r)
+	}
+	s.refresh()
+	return false, nil
+}
+
+func (s *state) insert(r ...rune) {
+	s.buf = append(s.buf[:s.pos], append(r, s.buf[s.pos:]...)...)
+	s.pos += len(r)
+}
+
+// delete removes buf[i:j], as far as it exists.
+func (s *state) delete(i, j int) {
+	if j > len(s.buf) {
+		j = len(s.buf)
+	}
+	if i >= j {
+		return
+	}
+	s.buf = append(s.buf[:i], s.buf[j:]...)
+	if s.pos > j {
+		s.pos -= j - i
+	} else if s.pos > i {
+		s.pos = i
+	}
+}
+
+// showHistory shows history line i, where len(history) is the new line.
+func (s *state) showHistory(i int) {
+	h := s.e.history
+	if i < 0 || i > len(h) || i == s.hist {
+		return
+	}
+	if s.hist == len(h) {
+		s.saved = append([]rune(nil), s.buf...)
+	}
+	s.hist = i
+	if i == len(h) {
+		s.buf = s.saved
+	} else {
+		s.buf = []rune(h[i])
+	}
+	s.pos = len(s.buf)
+}
+
+// search runs a reverse incremental search of the history, started by
+// Control-R. It returns the key that ended the search, for the caller
+// to act on; the line then holds the match. Control-G abandons the
+// search, leaving the line as it was.
+func (s *state) search() (rune, error) {
+	var query []rune
+	found := -1
+	buf, pos := s.buf, s.pos
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(s.e.history[i], string(query)) {
+				found = i
+				return
+			}
+		}
+	}
+	for {
+		match := ""
+		if found >= 0 {
+			match = s.e.history[found]
+		}
+		fmt.Fprintf(s.e.out, "\r(reverse-i-search)`%s': %s\x1b[K", string(query), match)
+		r, err := s.e.readKey()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case r == ctrl('R'):
+			if found > 0 {
+				find(found - 1)
+			}
+		case r == ctrl('G'):
+			s.buf, s.pos = buf, pos
+			s.refresh()
+			return keyUnknown, nil
+		case r == ctrl('H') || r == 127:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				found = -1
+				find(len(s.e.history) - 1)
+			}
+		case r >= ' ':
+			query = append(query, r)
+			if found < 0 {
+				found = len(s.e.history) - 1
+			}
+			start := found
+			found = -1
+			find(start)
+		default:
+			if found >= 0 {
+				s.buf = []rune(s.e.history[found])
+				s.pos = len(s.buf)
+				s.hist = found
+			}
+			return r, nil
+		}
+	}
+}
+
+// tab completes the text before the cursor. A sole completion replaces
+// it; otherwise the text is extended as far as the completions agree,
+// and if it cannot be, they are listed.
+func (s *state) tab() {
+	var start int
+	var words []string
+	if s.e.complete != nil {
+		start, words = s.e.complete(s.buf, s.pos)
+	}
+	if len(words) == 0 {
+		if start == s.pos {
+			s.insert(' ', ' ')
+		} else {
+			io.WriteString(s.e.out, "\a")
+		}
+		return
+	}
+	prefix := []rune(words[0])
+	for _, w := range words[1:] {
+		prefix = commonPrefix(prefix, []rune(w))
+	}
+	if len(prefix) > s.pos-start {
+		s.delete(start, s.pos)
+		s.insert(prefix...)
+		return
+	}
+	if len(words) > 1 {
+		s.list(words)
+	}
+}
+
+func commonPrefix(a, b []rune) []rune {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+// list shows words in columns below the line, which refresh then draws
+// afresh.
+func (s *state) list(words []string) {
+	const width = 80
+	more := len(words) - maxList
+	if more > 0 {
+		words = words[:maxList]
+	}
+	col := 0
+	for _, w := range words {
+		if n := utf8.RuneCountInString(w); n+2 > col {
+			col = n + 2
+		}
+	}
+	perLine := width / col
+	if perLine == 0 {
+		perLine = 1
+	}
+	var b strings.Builder
+	for i, w := range words {
+		if i%perLine == 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "%-*s", col, w)
+	}
+	if more > 0 {
+		fmt.Fprintf(&b, "\r\n... and %d more", more)
+	}
+	b.WriteString("\r\n")
+	io.WriteString(s.e.out, b.String())
+}
+
+func (s *state) newline() {
+	io.WriteString(s.e.out, "\r\n")
+}
+
+// refresh redraws the line, highlighting the parenthesis matching the
+// one before or at the cursor.
+func (s *state) refresh() {
+	var b strings.Builder
+	b.WriteByte('\r')
+	b.WriteString(s.prompt)
+	m := -1
+	if !s.done {
+		m = Match(s.buf, s.pos)
+	}
+	for i, r := range s.buf {
+		if i == m {
+			b.WriteString("\x1b[1;4m")
+			b.WriteRune(r)
+			b.WriteString("\x1b[0m")
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString("\x1b[K")
+	if n := len(s.buf) - s.pos; n > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", n)
+	}
+	io.WriteString(s.e.out, b.String())
+}
+
+func isWord(r rune) bool {
+	return r != ' ' && r != '\t' && r != '(' && r != ')' && r != '\''
+}
+
+// wordStart returns the start of the word before pos.
+func wordStart(buf []rune, pos int) int {
+	for pos > 0 && !isWord(buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWord(buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns the end of the word after pos.
+func wordEnd(buf []rune, pos int) int {
+	for pos < len(buf) && !isWord(buf[pos]) {
+		pos++
+	}
+	for pos < len(buf) && isWord(buf[pos]) {
+		pos++
+	}
+	return pos
+}
@@ -0,0 +1,209 @@
+package line
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// edit returns the lines read from the keys typed, and the error that
+// ended them.
+func edit(e *Editor, keys string) ([]string, error) {
+	e.in = bufio.NewReader(strings.NewReader(keys))
+	var lines []string
+	for {
+		l, err := e.ReadLine("> ")
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, l)
+		e.AddHistory(l)
+	}
+}
+
+const (
+	up    = "\x1b[A"
+	down  = "\x1b[B"
+	left  = "\x1b[D"
+	right = "\x1b[C"
+	home  = "\x1b[H"
+	end   = "\x1b[F"
+	del   = "\x1b[3~"
+	bs    = "\x7f"
+)
+
+func TestEditing(t *testing.T) {
+	for _, test := range []struct {
+		keys string
+		want []string
+	}{
+		{"abc\r", []string{"abc"}},
+		{"ac" + left + "b\r", []string{"abc"}},
+		{"bc" + home + "a" + end + "d\r", []string{"abcd"}},
+		{"abcd" + left + left + bs + del + "\r", []string{"ad"}},
+		{"ab\x01x\x05y\r", []string{"xaby"}},
+		{"hello world\x17there\r", []string{"hello there"}},
+		{"(upa a) b\x1bb\x1bbz\r", []string{"(upa za) b"}},
+		{"abc" + left + left + "\x0b\r", []string{"a"}},
+		{"abc" + left + "\x15\r", []string{"c"}},
+		{"one\rtwo\r" + up + up + "\r", []string{"one", "two", "one"}},
+		{"one\rtwo\r" + up + up + down + "\r", []string{"one", "two", "two"}},
+		{"one\rnew" + up + down + "!\r", []string{"one", "new!"}},
+		{"celi 1\rupa 2\r\x12cel\r", []string{"celi 1", "upa 2", "celi 1"}},
+		{"celi 1\rcelo 2\r\x12cel\x12" + right + "!\r", []string{"celi 1", "celo 2", "celi 1!"}},
+		{"x\r\x12zzz\rok\r", []string{"x", "", "ok"}},
+		{"keep\x12ke\x07\r", []string{"keep"}},
+		{"a\x02\x04\r", []string{""}},
+		{"\x1b[5~\x01ok\x1bx\r", []string{"ok"}},
+		{"partial", []string{"partial"}},
+	} {
+		got, err := edit(New(nil, io.Discard, -1), test.keys)
+		if err != io.EOF {
+			t.Errorf("%q: got error %v", test.keys, err)
+		}
+		if strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%q: got %q, want %q", test.keys, got, test.want)
+		}
+	}
+}
+
+func TestInterruptAndEOF(t *testing.T) {
+	e := New(nil, io.Discard, -1)
+	if got, err := edit(e, "abc\x03"); err != ErrInterrupt || len(got) != 0 {
+		t.Errorf("Control-C gave %q, %v", got, err)
+	}
+	if got, err := edit(e, "\x04more"); err != io.EOF || len(got) != 0 {
+		t.Errorf("Control-D gave %q, %v", got, err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	words := []string{"celi", "celida", "kucha", "kulawa"}
+	complete := func(l []rune, pos int) (int, []string) {
+		start := wordStart(l, pos)
+		var found []string
+		for _, w := range words {
+			if start < pos && strings.HasPrefix(w, string(l[start:pos])) {
+				found = append(found, w)
+			}
+		}
+		return start, found
+	}
+	for _, test := range []struct {
+		keys, want, shown string
+	}{
+		{"(kuc\t 1)\r", "(kucha 1)", ""},
+		{"(ku\t\t\r", "(ku", "kucha   kulawa"},
+		{"(c\tda\r", "(celida", ""},
+		{"(c\t\t\r", "(celi", "celi    celida"},
+		{"\t(x\r", "  (x", ""},
+		{"(zz\t\r", "(zz", "\a"},
+		{"(ab" + left + left + "kuc\t\r", "(kuchaab", ""},
+	} {
+		var out strings.Builder
+		e := New(nil, &out, -1)
+		e.SetCompleter(complete)
+		got, _ := edit(e, test.keys)
+		if len(got) != 1 || got[0] != test.want {
+			t.Errorf("%q: got %q, want %q", test.keys, got, test.want)
+		}
+		if test.shown != "" && !strings.Contains(out.String(), test.shown) {
+			t.Errorf("%q: %q not shown in %q", test.keys, test.shown, out.String())
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	var out strings.Builder
+	e := New(bufio.NewReader(strings.NewReader("(a (b))\r")), &out, -1)
+	if _, err := e.ReadLine("> "); err != nil {
+		t.Fatal(err)
+	}
+	draws := strings.Split(out.String(), "\r")
+	const want = "> \x1b[1;4m(\x1b[0ma (b))\x1b[K"
+	if got := draws[len(draws)-3]; got != want {
+		t.Errorf("last draw before return is %q, want %q", got, want)
+	}
+	if got := draws[len(draws)-2]; strings.Contains(got, "\x1b[1;4m") {
+		t.Errorf("finished line is still highlighted: %q", got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		pos  int
+		want int
+	}{
+		{"(a (b))", 7, 0},
+		{"(a (b))", 6, 3},
+		{"(a (b))", 0, 6},
+		{"(a (b))", 3, 5},
+		{"(a (b))", 1, -1},
+		{`(a ")" b)`, 9, 0},
+		{"(a ; )\n)", 8, 0},
+		{"a)", 2, -1},
+		{"(a", 0, -1},
+	} {
+		if got := Match([]rune(test.src), test.pos); got != test.want {
+			t.Errorf("Match(%q, %d) = %d, want %d", test.src, test.pos, got, test.want)
+		}
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		want bool
+	}{
+		{"(celi 1 2)\n", false},
+		{"(celi 1\n", true},
+		{"(upa 'a \")\"\n", true},
+		{"(a ; )\n", true},
+		{"\"open\n", true},
+		{"\"a \\\" b\"\n", false},
+		{"'\n", true},
+		{"'a\n", false},
+		{"a))\n", false},
+		{"\n", false},
+	} {
+		if got := Incomplete(test.src); got != test.want {
+			t.Errorf("Incomplete(%q) = %v, want %v", test.src, got, test.want)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mita", "history")
+	e := New(nil, io.Discard, -1)
+	if err := e.SetHistoryFile(file); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"one", "", "two", "two", "(a\n b)"} {
+		if err := e.AddHistory(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e = New(nil, io.Discard, -1)
+	if err := e.SetHistoryFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(e.History(), "|"); got != "one|two|(a  b)" {
+		t.Errorf("history is %q", got)
+	}
+
+	many := strings.Repeat("x\ny\n", maxHistory)
+	if err := os.WriteFile(file, []byte(many), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetHistoryFile(file); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(file)
+	if len(e.History()) != maxHistory || strings.Count(string(data), "\n") != maxHistory {
+		t.Errorf("kept %d lines, file has %d", len(e.History()), strings.Count(string(data), "\n"))
+	}
+}
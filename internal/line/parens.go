@@ -0,0 +1,88 @@
+package line
+
+// parens returns, for each parenthesis in src, the index of the one
+// that matches it, or -1 if none does. Parentheses in strings and
+// comments do not count.
+func parens(src []rune) map[int]int {
+	m := make(map[int]int)
+	var open []int
+	inString, inComment := false, false
+	for i := 0; i < len(src); i++ {
+		switch r := src[i]; {
+		case inComment:
+			inComment = r != '\n'
+		case inString:
+			if r == '\\' {
+				i++
+			} else if r == '"' {
+				inString = false
+			}
+		case r == '"':
+			inString = true
+		case r == ';':
+			inComment = true
+		case r == '(':
+			open = append(open, i)
+			m[i] = -1
+		case r == ')':
+			m[i] = -1
+			if n := len(open); n > 0 {
+				m[i], m[open[n-1]] = open[n-1], i
+				open = open[:n-1]
+			}
+		}
+	}
+	return m
+}
+
+// Match returns the index in src of the parenthesis matching the closing
+// one before pos or, failing that, the opening one at pos. It returns
+// -1 if there is no such pair.
+func Match(src []rune, pos int) int {
+	m := parens(src)
+	if pos > 0 && src[pos-1] == ')' {
+		if j, ok := m[pos-1]; ok {
+			return j
+		}
+	}
+	if pos < len(src) && src[pos] == '(' {
+		if j, ok := m[pos]; ok {
+			return j
+		}
+	}
+	return -1
+}
+
+// Incomplete reports whether src is unfinished: it has a parenthesis or
+// a string still open, or ends with a quote.
+func Incomplete(src string) bool {
+	depth := 0
+	inString, inComment, quoted := false, false, false
+	r := []rune(src)
+	for i := 0; i < len(r); i++ {
+		switch c := r[i]; {
+		case inComment:
+			inComment = c != '\n'
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		case c == ';':
+			inComment = true
+		default:
+			quoted = c == '\''
+			switch c {
+			case '"':
+				inString = true
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+	}
+	return depth > 0 || inString || quoted
+}
@@ -0,0 +1,247 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/mitalang/mita"
+)
+
+// A document is an open source file, as the client last sent it, with
+// what the server needs to know of its definitions and names.
+type document struct {
+	uri     string
+	text    string
+	lines   []string
+	nodes   []*mita.Node // nil if the text cannot be read
+	err     error        // why it cannot
+	ns      string       // the namespace it declares, if any
+	exports map[string]bool
+	defs    []*definition
+	aliases map[string]string // alias to namespace
+	imports []importSpec
+}
+
+// A definition is a name defined by a muhe form.
+type definition struct {
+	name   *mita.Node
+	def    *mita.Node // (name value)
+	params *mita.Node // the parameters, if the value is a mita form
+	doc    string     // the comments on the lines before it
+}
+
+// An importSpec is an import form other than one making an alias.
+type importSpec struct {
+	ns    string
+	names []string // or all the namespace exports, if nil
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+	d.nodes, d.err = mita.ReadSyntax([]byte(text))
+	forms := elems(d.nodes)
+	if len(forms) > 0 && isForm(forms[0], "namespace") {
+		if a := args(forms[0]); len(a) > 0 && a[0].Kind == mita.NodeSymbol {
+			d.ns = a[0].Text
+			d.exports = make(map[string]bool)
+			if len(a) > 1 {
+				for _, x := range a[1].Elems() {
+					d.exports[x.Text] = true
+				}
+			}
+		}
+	}
+	for _, form := range forms {
+		switch {
+		case isForm(form, "muhe"):
+			if a := args(form); len(a) == 1 && a[0].Kind == mita.NodeList {
+				d.definitions(a[0].Nodes)
+			}
+		case isForm(form, "import"):
+			a := args(form)
+			if len(a) == 0 || a[0].Kind != mita.NodeSymbol {
+				break
+			}
+			switch {
+			case len(a) == 1:
+				d.imports = append(d.imports, importSpec{ns: a[0].Text})
+			case a[1].Kind == mita.NodeSymbol:
+				if d.aliases == nil {
+					d.aliases = make(map[string]string)
+				}
+				d.aliases[a[1].Text] = a[0].Text
+			default:
+				spec := importSpec{ns: a[0].Text, names: []string{}}
+				for _, x := range a[1].Elems() {
+					spec.names = append(spec.names, x.Text)
+				}
+				d.imports = append(d.imports, spec)
+			}
+		}
+	}
+	return d
+}
+
+// definitions records the definitions of a muhe form, nodes being the
+// elements of its list of them.
+func (d *document) definitions(nodes []*mita.Node) {
+	var comments []string
+	for _, x := range nodes {
+		if x.Kind == mita.NodeComment {
+			if !x.Trailing {
+				comments = append(comments, strings.TrimSpace(strings.TrimLeft(x.Text, ";")))
+			}
+			continue
+		}
+		def := &definition{def: x, doc: strings.Join(comments, "\n")}
+		comments = nil
+		e := x.Elems()
+		if x.Kind != mita.NodeList || len(e) == 0 || e[0].Kind != mita.NodeSymbol {
+			continue
+		}
+		def.name = e[0]
+		if len(e) > 1 && isForm(e[1], "mita") {
+			if f := e[1].Elems(); len(f) > 1 && f[1].Kind == mita.NodeList {
+				def.params = f[1]
+			}
+		}
+		d.defs = append(d.defs, def)
+	}
+}
+
+// qualify returns the name under which d defines name.
+func (d *document) qualify(name string) string {
+	if d.ns == "" {
+		return name
+	}
+	return d.ns + ":" + name
+}
+
+// position returns p as an LSP position.
+func (d *document) position(p mita.Pos) position {
+	line := p.Line - 1
+	if line < 0 {
+		return position{}
+	}
+	if line >= len(d.lines) {
+		return d.end()
+	}
+	char, col := 0, 1
+	for _, r := range d.lines[line] {
+		if col == p.Col {
+			break
+		}
+		char += utf16Len(r)
+		col++
+	}
+	return position{line, char}
+}
+
+// pos returns the LSP position p as a mita.Pos.
+func (d *document) pos(p position) mita.Pos {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return mita.Pos{Line: p.Line + 1, Col: 1}
+	}
+	char, col := 0, 1
+	for _, r := range d.lines[p.Line] {
+		if char >= p.Character {
+			break
+		}
+		char += utf16Len(r)
+		col++
+	}
+	return mita.Pos{Line: p.Line + 1, Col: col}
+}
+
+// end returns the position at the end of the text.
+func (d *document) end() position {
+	last := d.lines[len(d.lines)-1]
+	char := 0
+	for _, r := range last {
+		char += utf16Len(r)
+	}
+	return position{len(d.lines) - 1, char}
+}
+
+func (d *document) span(n *mita.Node) span {
+	return span{d.position(n.Pos), d.position(n.End)}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// atomAt returns the symbol or constant at p, or nil. The cursor may be
+// just after it.
+func (d *document) atomAt(p position) *mita.Node {
+	at := d.pos(p)
+	var found *mita.Node
+	walk(d.nodes, func(n *mita.Node) {
+		if n.Kind != mita.NodeSymbol && n.Kind != mita.NodeConst || n.Pos.Line != at.Line {
+			return
+		}
+		if n.Pos.Col <= at.Col && at.Col < n.End.Col || found == nil && at.Col == n.End.Col {
+			found = n
+		}
+	})
+	return found
+}
+
+// walk calls f for each Node in nodes and in the Nodes within them.
+func walk(nodes []*mita.Node, f func(*mita.Node)) {
+	for _, n := range nodes {
+		f(n)
+		walk(n.Nodes, f)
+	}
+}
+
+// symbols calls f for each symbol in nodes that is not, and does not
+// refer to, a parameter of a mita form around it.
+func symbols(nodes []*mita.Node, params map[string]bool, f func(*mita.Node)) {
+	for _, n := range nodes {
+		switch n.Kind {
+		case mita.NodeSymbol:
+			if !params[n.Text] {
+				f(n)
+			}
+		case mita.NodeQuote:
+			symbols(n.Nodes, params, f)
+		case mita.NodeList:
+			e := n.Elems()
+			if isForm(n, "mita") && len(e) > 1 && e[1].Kind == mita.NodeList {
+				inner := make(map[string]bool)
+				for name := range params {
+					inner[name] = true
+				}
+				for _, x := range e[1].Elems() {
+					inner[x.Text] = true
+				}
+				symbols(e[2:], inner, f)
+				continue
+			}
+			symbols(n.Nodes, params, f)
+		}
+	}
+}
+
+// elems returns nodes without their comments.
+func elems(nodes []*mita.Node) []*mita.Node {
+	return (&mita.Node{Nodes: nodes}).Elems()
+}
+
+// isForm reports whether n is a list headed by the symbol head.
+func isForm(n *mita.Node, head string) bool {
+	e := n.Elems()
+	return n.Kind == mita.NodeList && len(e) > 0 && e[0].Kind == mita.NodeSymbol && e[0].Text == head
+}
+
+// args returns the elements of a list after its head.
+func args(n *mita.Node) []*mita.Node {
+	e := n.Elems()
+	if len(e) == 0 {
+		return nil
+	}
+	return e[1:]
+}
@@ -0,0 +1,508 @@
+// Package lsp is a language server for mita, speaking the Language
+// Server Protocol to an editor. It reports the diagnostics of mita vet
+// as files change, finds the definitions and uses of the names muhe
+// defines, says in English what builtins mean on hover, and completes,
+// lists the definitions of and formats files.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mitalang/mita"
+)
+
+type server struct {
+	in          *bufio.Reader
+	out         io.Writer
+	docs        map[string]*document // by URI
+	context     *mita.Context        // for the names a fresh program has
+	initialized bool
+	shutdown    bool
+}
+
+// Serve answers the requests read from r, writing responses and
+// notifications to w, until an exit notification. It returns nil if
+// a shutdown request came before it, as the protocol requires.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{
+		in:      bufio.NewReader(r),
+		out:     w,
+		docs:    make(map[string]*document),
+		context: mita.NewContext(0),
+	}
+	for {
+		m, err := readMessage(s.in)
+		if e, ok := err.(*responseError); ok {
+			if err := s.reply(nil, nil, e); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return errors.New("input ended before exit")
+		}
+		if err != nil {
+			return err
+		}
+		if m.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(m)
+		if m.ID == nil {
+			continue // A notification gets no response.
+		}
+		if err := s.reply(m.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) reply(id json.RawMessage, result interface{}, err error) error {
+	m := &message{ID: id}
+	if id == nil {
+		m.ID = json.RawMessage("null")
+	}
+	if err != nil {
+		e, ok := err.(*responseError)
+		if !ok {
+			e = &responseError{codeRequestFailed, err.Error()}
+		}
+		m.Error = e
+		return writeMessage(s.out, m)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	m.Result = data
+	return writeMessage(s.out, m)
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.out, &message{Method: method, Params: data})
+}
+
+// handle answers a request or acts on a notification.
+func (s *server) handle(m *message) (interface{}, error) {
+	switch {
+	case m.Method == "initialize":
+		s.initialized = true
+		var result initializeResult
+		c := &result.Capabilities
+		c.TextDocumentSync = syncFull
+		c.DefinitionProvider = true
+		c.ReferencesProvider = true
+		c.HoverProvider = true
+		c.DocumentSymbolProvider = true
+		c.DocumentFormattingProvider = true
+		result.ServerInfo.Name = "mita"
+		return result, nil
+	case !s.initialized:
+		return nil, &responseError{codeServerNotInitialized, "not initialized"}
+	case s.shutdown:
+		return nil, &responseError{codeInvalidRequest, "shut down"}
+	}
+	handler, ok := handlers[m.Method]
+	if !ok {
+		if strings.HasPrefix(m.Method, "$/") || m.ID == nil {
+			return nil, nil // Optional notifications may be ignored.
+		}
+		return nil, &responseError{codeMethodNotFound, "unknown method " + m.Method}
+	}
+	return handler(s, m.Params)
+}
+
+var handlers map[string]func(s *server, params json.RawMessage) (interface{}, error)
+
+func init() {
+	handlers = map[string]func(s *server, params json.RawMessage) (interface{}, error){
+		"initialized":                 func(*server, json.RawMessage) (interface{}, error) { return nil, nil },
+		"shutdown":                    (*server).shutdownRequest,
+		"textDocument/didOpen":        (*server).didOpen,
+		"textDocument/didChange":      (*server).didChange,
+		"textDocument/didClose":       (*server).didClose,
+		"textDocument/definition":     (*server).definition,
+		"textDocument/references":     (*server).references,
+		"textDocument/hover":          (*server).hover,
+		"textDocument/completion":     (*server).completion,
+		"textDocument/documentSymbol": (*server).documentSymbol,
+		"textDocument/formatting":     (*server).formatting,
+	}
+}
+
+// decode unmarshals params into v.
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+// doc returns the open document uri.
+func (s *server) doc(uri string) (*document, error) {
+	d := s.docs[uri]
+	if d == nil {
+		return nil, &responseError{codeInvalidParams, "no open document " + uri}
+	}
+	return d, nil
+}
+
+func (s *server) shutdownRequest(json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p didOpenParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	s.docs[p.TextDocument.URI] = newDocument(p.TextDocument.URI, p.TextDocument.Text)
+	return nil, s.diagnose()
+}
+
+func (s *server) didChange(params json.RawMessage) (interface{}, error) {
+	var p didChangeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if n := len(p.ContentChanges); n > 0 {
+		s.docs[p.TextDocument.URI] = newDocument(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+	}
+	return nil, s.diagnose()
+}
+
+func (s *server) didClose(params json.RawMessage) (interface{}, error) {
+	var p didCloseParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	err := s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{p.TextDocument.URI, []diagnostic{}})
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.diagnose()
+}
+
+// uris returns the URIs of the open documents, sorted.
+func (s *server) uris() []string {
+	var uris []string
+	for uri := range s.docs {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// diagnose publishes the diagnostics of every open document, which
+// mita.Vet checks as one program.
+func (s *server) diagnose() error {
+	var srcs []mita.Source
+	for _, uri := range s.uris() {
+		srcs = append(srcs, mita.Source{Name: uri, Text: []byte(s.docs[uri].text)})
+	}
+	byURI := make(map[string][]diagnostic)
+	for _, v := range mita.Vet(srcs) {
+		d := s.docs[v.File]
+		severity := severityWarning
+		if d.err != nil {
+			severity = severityError
+		}
+		byURI[v.File] = append(byURI[v.File], diagnostic{
+			Range:    span{d.position(v.Pos), d.position(v.End)},
+			Severity: severity,
+			Source:   "mita",
+			Message:  v.Msg,
+		})
+	}
+	for _, uri := range s.uris() {
+		diags := byURI[uri]
+		if diags == nil {
+			diags = []diagnostic{}
+		}
+		if err := s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, diags}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve returns the name that name, as written in d, refers to, with
+// its namespace if it has one.
+func (s *server) resolve(d *document, name string) string {
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		if ns, ok := d.aliases[name[:i]]; ok {
+			return ns + ":" + name[i+1:]
+		}
+		return name
+	}
+	if d.ns != "" {
+		for _, def := range d.defs {
+			if def.name.Text == name {
+				return d.qualify(name)
+			}
+		}
+	}
+	resolved := name
+	for _, spec := range d.imports {
+		if spec.names == nil && s.exports(spec.ns)[name] || contains(spec.names, name) {
+			resolved = spec.ns + ":" + name
+		}
+	}
+	return resolved
+}
+
+// exports returns the names the open document declaring namespace ns
+// exports.
+func (s *server) exports(ns string) map[string]bool {
+	for _, d := range s.docs {
+		if d.ns == ns {
+			return d.exports
+		}
+	}
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the document and the name resolved there of the
+// symbol at a position, or nil if there is none.
+func (s *server) lookup(p positionParams) (*document, *mita.Node, string, error) {
+	d, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	n := d.atomAt(p.Position)
+	if n == nil || n.Kind != mita.NodeSymbol {
+		return d, n, "", nil
+	}
+	return d, n, s.resolve(d, n.Text), nil
+}
+
+// definitionsOf returns the definitions of the resolved name, and the
+// documents they are in.
+func (s *server) definitionsOf(name string) ([]*definition, []*document) {
+	var defs []*definition
+	var docs []*document
+	for _, uri := range s.uris() {
+		d := s.docs[uri]
+		for _, def := range d.defs {
+			if d.qualify(def.name.Text) == name {
+				defs, docs = append(defs, def), append(docs, d)
+			}
+		}
+	}
+	return defs, docs
+}
+
+func (s *server) definition(params json.RawMessage) (interface{}, error) {
+	var p positionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	_, _, name, err := s.lookup(p)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	locs := []location{}
+	defs, docs := s.definitionsOf(name)
+	for i, def := range defs {
+		locs = append(locs, location{docs[i].uri, docs[i].span(def.name)})
+	}
+	return locs, nil
+}
+
+func (s *server) references(params json.RawMessage) (interface{}, error) {
+	var p referenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	_, _, name, err := s.lookup(p.positionParams)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	locs := []location{}
+	for _, uri := range s.uris() {
+		d := s.docs[uri]
+		defined := make(map[*mita.Node]bool)
+		for _, def := range d.defs {
+			defined[def.name] = true
+		}
+		symbols(d.nodes, nil, func(n *mita.Node) {
+			if s.resolve(d, n.Text) == name && (p.Context.IncludeDeclaration || !defined[n]) {
+				locs = append(locs, location{uri, d.span(n)})
+			}
+		})
+	}
+	return locs, nil
+}
+
+func (s *server) hover(params json.RawMessage) (interface{}, error) {
+	var p positionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, n, name, err := s.lookup(p)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	if name == "" {
+		name = n.Text // A constant.
+	}
+	var b strings.Builder
+	defs, docs := s.definitionsOf(name)
+	switch meaning := mita.Meaning(name); {
+	case meaning != "" && (len(defs) == 0 || mita.IsBuiltin(name) || mita.IsSpecialForm(name)):
+		fmt.Fprintf(&b, "```mita\n%s\n```\n%s", n.Text, meaning)
+	case len(defs) > 0:
+		def, in := defs[len(defs)-1], docs[len(defs)-1]
+		fmt.Fprintf(&b, "```mita\n%s\n```\n", signature(def))
+		if def.doc != "" {
+			fmt.Fprintf(&b, "%s\n\n", def.doc)
+		}
+		fmt.Fprintf(&b, "Defined in %s:%s", path.Base(in.uri), def.name.Pos)
+	default:
+		return nil, nil
+	}
+	return hover{markupContent{"markdown", b.String()}, d.span(n)}, nil
+}
+
+// signature returns how a call of def is written, or its name if it
+// is not a function.
+func signature(def *definition) string {
+	if def.params == nil {
+		return def.name.Text
+	}
+	sig := def.name.Text
+	for _, x := range def.params.Elems() {
+		sig += " " + x.Text
+	}
+	return "(" + sig + ")"
+}
+
+func (s *server) completion(params json.RawMessage) (interface{}, error) {
+	var p positionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	var before []rune
+	if p.Position.Line < len(d.lines) {
+		at := d.pos(p.Position)
+		before = []rune(d.lines[p.Position.Line])[:at.Col-1]
+	}
+	start := len(before)
+	for start > 0 && !strings.ContainsRune(" \t\r()'\"", before[start-1]) {
+		start--
+	}
+	prefix := string(before[start:])
+	items := []completionItem{}
+	seen := make(map[string]bool)
+	add := func(label string, kind int, detail string) {
+		if !seen[label] && strings.HasPrefix(label, prefix) {
+			seen[label] = true
+			items = append(items, completionItem{label, kind, detail})
+		}
+	}
+	for _, uri := range s.uris() {
+		other := s.docs[uri]
+		for _, def := range other.defs {
+			label := def.name.Text
+			if other != d && other.ns != "" {
+				if !other.exports[label] {
+					continue
+				}
+				label = other.qualify(label)
+			}
+			kind := completionFunction
+			if def.params == nil {
+				kind = completionVariable
+			}
+			add(label, kind, signature(def))
+		}
+	}
+	for _, name := range s.context.Complete(prefix) {
+		kind := completionFunction
+		switch {
+		case mita.IsSpecialForm(name):
+			kind = completionKeyword
+		case mita.IsConstant(name):
+			kind = completionConstant
+		}
+		add(name, kind, mita.Meaning(name))
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items, nil
+}
+
+func (s *server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p documentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := []documentSymbol{}
+	if forms := elems(d.nodes); d.ns != "" {
+		name := args(forms[0])[0]
+		symbols = append(symbols, documentSymbol{
+			Name: d.ns, Kind: symbolNamespace, Range: d.span(forms[0]), SelectionRange: d.span(name),
+		})
+	}
+	for _, def := range d.defs {
+		sym := documentSymbol{
+			Name: def.name.Text, Kind: symbolVariable, Range: d.span(def.def), SelectionRange: d.span(def.name),
+		}
+		if def.params != nil {
+			sym.Kind, sym.Detail = symbolFunction, signature(def)
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols, nil
+}
+
+func (s *server) formatting(params json.RawMessage) (interface{}, error) {
+	var p documentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.doc(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	out, err := mita.Format([]byte(d.text))
+	if err != nil {
+		return nil, &responseError{codeRequestFailed, err.Error()}
+	}
+	if string(out) == d.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{span{position{}, d.end()}, string(out)}}, nil
+}
@@ -0,0 +1,302 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mitalang/mita"
+)
+
+// A client talks to a server running in the same process.
+type client struct {
+	t     *testing.T
+	w     io.WriteCloser
+	msgs  chan *message
+	notes []*message // notifications read while waiting for a response
+	id    int
+	done  chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, msgs: make(chan *message, 100), done: make(chan error, 1)}
+	go func() {
+		err := Serve(inR, outW)
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			m, err := readMessage(r)
+			if err != nil {
+				close(c.msgs)
+				return
+			}
+			c.msgs <- m
+		}
+	}()
+	return c
+}
+
+func (c *client) send(m *message) {
+	c.t.Helper()
+	if err := writeMessage(c.w, m); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	data, _ := json.Marshal(params)
+	c.send(&message{Method: method, Params: data})
+}
+
+// call sends a request and decodes its result into result.
+func (c *client) call(method string, params, result interface{}) *responseError {
+	c.t.Helper()
+	c.id++
+	data, _ := json.Marshal(params)
+	id, _ := json.Marshal(c.id)
+	c.send(&message{ID: id, Method: method, Params: data})
+	for m := range c.msgs {
+		if m.Method != "" {
+			c.notes = append(c.notes, m)
+			continue
+		}
+		if string(m.ID) != string(id) {
+			c.t.Fatalf("%s: response to %s", method, m.ID)
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+		if err := json.Unmarshal(m.Result, result); err != nil {
+			c.t.Fatalf("%s: %v", method, err)
+		}
+		return nil
+	}
+	c.t.Fatalf("%s: server stopped", method)
+	return nil
+}
+
+// diagnostics returns the diagnostics next published for uri.
+func (c *client) diagnostics(uri string) []diagnostic {
+	c.t.Helper()
+	for {
+		var m *message
+		if len(c.notes) > 0 {
+			m, c.notes = c.notes[0], c.notes[1:]
+		} else if m = <-c.msgs; m == nil {
+			c.t.Fatal("server stopped")
+		}
+		var p publishDiagnosticsParams
+		if m.Method == "textDocument/publishDiagnostics" && json.Unmarshal(m.Params, &p) == nil && p.URI == uri {
+			return p.Diagnostics
+		}
+	}
+}
+
+func (c *client) open(uri, text string) {
+	c.t.Helper()
+	var p didOpenParams
+	p.TextDocument.URI, p.TextDocument.Text = uri, text
+	c.notify("textDocument/didOpen", p)
+}
+
+func at(uri string, line, char int) positionParams {
+	return positionParams{textDocumentIdentifier{uri}, position{line, char}}
+}
+
+const (
+	fibURI  = "file:///src/fib.mita"
+	fibText = `; Fibonacci numbers.
+(muhe (
+  ; yafib is the si'th Fibonacci number.
+  (yafib (mita (si)
+           (dala ((aba si du) si)
+                 (da (celi (yafib (movo si unu)) (yafib (movo si du)))))))
+  (twice (mita (x) (celi x x)))))
+(twice (yafib (kucha '(1 9))))
+
+
+`
+)
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+	var init initializeResult
+	if err := c.call("textDocument/hover", at(fibURI, 0, 0), &init); err == nil || err.Code != codeServerNotInitialized {
+		t.Errorf("request before initialize: got %v", err)
+	}
+	if err := c.call("initialize", struct{}{}, &init); err != nil {
+		t.Fatal(err)
+	}
+	if caps := init.Capabilities; !caps.HoverProvider || !caps.DefinitionProvider || caps.TextDocumentSync != syncFull {
+		t.Errorf("capabilities: %+v", caps)
+	}
+	c.notify("initialized", struct{}{})
+
+	c.open(fibURI, fibText)
+	if diags := c.diagnostics(fibURI); len(diags) != 0 {
+		t.Errorf("diagnostics: %+v", diags)
+	}
+
+	// The yafib in (yafib (kucha ...)) on the last line.
+	var locs []location
+	if err := c.call("textDocument/definition", at(fibURI, 7, 9), &locs); err != nil {
+		t.Fatal(err)
+	}
+	want := location{fibURI, span{position{3, 3}, position{3, 8}}}
+	if len(locs) != 1 || locs[0] != want {
+		t.Errorf("definition: got %+v, want %+v", locs, want)
+	}
+
+	var p referenceParams
+	p.positionParams = at(fibURI, 7, 10)
+	p.Context.IncludeDeclaration = true
+	if err := c.call("textDocument/references", p, &locs); err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, l := range locs {
+		lines = append(lines, l.Range.Start.Line)
+	}
+	if got, want := fmtInts(lines), "3 5 5 7"; got != want {
+		t.Errorf("references: got lines %s, want %s", got, want)
+	}
+
+	var h hover
+	if err := c.call("textDocument/hover", at(fibURI, 7, 17), &h); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(h.Contents.Value, "cdr: the rest of a list") {
+		t.Errorf("hover on kucha: %q", h.Contents.Value)
+	}
+	if err := c.call("textDocument/hover", at(fibURI, 7, 1), &h); err != nil {
+		t.Fatal(err)
+	}
+	if want := "```mita\n(twice x)\n```\nDefined in fib.mita:7:4"; h.Contents.Value != want {
+		t.Errorf("hover on twice: got %q, want %q", h.Contents.Value, want)
+	}
+	if err := c.call("textDocument/hover", at(fibURI, 5, 29), &h); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(h.Contents.Value, "yafib is the si'th Fibonacci number.") {
+		t.Errorf("hover on yafib: %q", h.Contents.Value)
+	}
+
+	var items []completionItem
+	if err := c.call("textDocument/completion", at(fibURI, 7, 19), &items); err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	if got, want := strings.Join(labels, " "), "kucha kuchada"; got != want {
+		t.Errorf("completion: got %s, want %s", got, want)
+	}
+
+	var syms []documentSymbol
+	if err := c.call("textDocument/documentSymbol", documentParams{textDocumentIdentifier{fibURI}}, &syms); err != nil {
+		t.Fatal(err)
+	}
+	if len(syms) != 2 || syms[0].Name != "yafib" || syms[0].Kind != symbolFunction || syms[1].Detail != "(twice x)" {
+		t.Errorf("document symbols: %+v", syms)
+	}
+
+	var edits []textEdit
+	if err := c.call("textDocument/formatting", documentParams{textDocumentIdentifier{fibURI}}, &edits); err != nil {
+		t.Fatal(err)
+	}
+	formatted, _ := mita.Format([]byte(fibText))
+	if len(edits) != 1 || edits[0].NewText != string(formatted) || edits[0].Range.End != (position{10, 0}) {
+		t.Errorf("formatting: %+v", edits)
+	}
+
+	var change didChangeParams
+	change.TextDocument.URI = fibURI
+	change.ContentChanges = append(change.ContentChanges, struct {
+		Text string `json:"text"`
+	}{"(yafib 1 2)\n(celi 1"})
+	c.notify("textDocument/didChange", change)
+	diags := c.diagnostics(fibURI)
+	if len(diags) != 1 || diags[0].Severity != severityError || diags[0].Message != "unexpected EOF in list" {
+		t.Errorf("diagnostics after change: %+v", diags)
+	}
+	if err := c.call("textDocument/formatting", documentParams{textDocumentIdentifier{fibURI}}, &edits); err == nil {
+		t.Errorf("formatting unreadable text succeeded")
+	}
+
+	if err := c.call("shutdown", nil, new(interface{})); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
+
+func TestServerNamespaces(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", struct{}{}, new(initializeResult))
+	const (
+		listsURI = "file:///src/lists.mita"
+		userURI  = "file:///src/user.mita"
+	)
+	c.open(listsURI, "(namespace lists (map2))\n(muhe ((map2 (mita (f l) (map f l)))))\n")
+	c.open(userURI, "(import lists l)\n(muhe ((map2 (mita () 1))))\n(l:map2 'map2 '(1 2))\n(map2)\n(lists:map2 'map2 nya)\n")
+	if diags := c.diagnostics(userURI); len(diags) != 0 {
+		t.Errorf("diagnostics: %+v", diags)
+	}
+
+	var locs []location
+	c.call("textDocument/definition", at(userURI, 2, 3), &locs)
+	if len(locs) != 1 || locs[0].URI != listsURI || locs[0].Range.Start != (position{1, 8}) {
+		t.Errorf("definition of l:map2: %+v", locs)
+	}
+	c.call("textDocument/definition", at(userURI, 3, 1), &locs)
+	if len(locs) != 1 || locs[0].URI != userURI || locs[0].Range.Start != (position{1, 8}) {
+		t.Errorf("definition of map2: %+v", locs)
+	}
+
+	var items []completionItem
+	for _, test := range []struct {
+		at   positionParams
+		want string
+	}{
+		{at(userURI, 3, 4), "map map2"},
+		{at(userURI, 4, 7), "lists:map2"},
+	} {
+		c.call("textDocument/completion", test.at, &items)
+		var labels []string
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		if got := strings.Join(labels, " "); got != test.want {
+			t.Errorf("completion at %+v: got %s, want %s", test.at.Position, got, test.want)
+		}
+	}
+}
+
+// TestPositions checks that positions count UTF-16 code units, as the
+// protocol says, while mita counts runes.
+func TestPositions(t *testing.T) {
+	d := newDocument("file:///u.mita", "(display \"😀\" 'é)\n")
+	sym := d.atomAt(position{0, 15})
+	if sym == nil || sym.Text != "é" {
+		t.Fatalf("atomAt: got %v", sym)
+	}
+	if got, want := d.span(sym), (span{position{0, 15}, position{0, 16}}); got != want {
+		t.Errorf("span: got %+v, want %+v", got, want)
+	}
+}
+
+func fmtInts(x []int) string {
+	b, _ := json.Marshal(x)
+	return strings.Trim(strings.ReplaceAll(string(b), ",", " "), "[]")
+}
@@ -0,0 +1,212 @@
+package mita
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+var (
+	tokJSONDecode = makeTiga("jsondecode") // a value from JSON text
+	tokJSONEncode = makeTiga("jsonencode") // JSON text for a value
+
+	tokObject = makeTiga("object") // heads a JSON object
+	tokFloat  = makeTiga("float")  // heads a JSON number that is not an integer
+)
+
+// DecodeJSON returns the value of the JSON text data. Objects become
+// association lists headed by the symbol object, (object (key . value)
+// ...), with symbols for keys and in the order written; arrays become
+// lists; true, false and null become da, nye and nya; strings become
+// strings. Numbers that are integers become numbers and others become
+// (float "1.5"), holding the number as written, so no precision is
+// lost. EncodeJSON turns the value back into the same JSON.
+func (c *Context) DecodeJSON(data []byte) (x *Expr, err error) {
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+		case Error:
+			x, err = nil, errors.New(string(e))
+		default:
+			panic(e)
+		}
+	}()
+	return c.decodeJSON(data), nil
+}
+
+// EncodeJSON returns the JSON text for e, as DecodeJSON reads it. A list
+// headed by object is an object, and its other elements must be pairs
+// with symbols for keys; a list headed by float is a number; other
+// lists are arrays. Symbols become strings, and nil is an empty array.
+// Functions, tasks, channels and dotted pairs outside objects cannot
+// be encoded.
+func EncodeJSON(e *Expr) (data []byte, err error) {
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+		case Error:
+			data, err = nil, errors.New(string(e))
+		default:
+			panic(e)
+		}
+	}()
+	var b bytes.Buffer
+	encodeJSON(&b, e)
+	return b.Bytes(), nil
+}
+
+// decodeJSON is DecodeJSON raising errors.
+func (c *Context) decodeJSON(data []byte) *Expr {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	x := c.decodeJSONValue(d, jsonToken(d))
+	if _, err := d.Token(); err != io.EOF {
+		errorf("json: text after value")
+	}
+	return x
+}
+
+// jsonToken returns the next token from d, which must exist.
+func jsonToken(d *json.Decoder) json.Token {
+	t, err := d.Token()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		errorf("json: %v", err)
+	}
+	return t
+}
+
+func (c *Context) decodeJSONValue(d *json.Decoder, t json.Token) *Expr {
+	switch t := t.(type) {
+	case nil:
+		return constNya
+	case bool:
+		return truthExpr(t)
+	case string:
+		return c.newString(t)
+	case json.Number:
+		if n, err := strconv.Atoi(string(t)); err == nil {
+			return c.number(n)
+		}
+		return c.upa(c.tiga(tokFloat), c.upa(c.newString(string(t)), nil))
+	case json.Delim:
+		var items []*Expr
+		if t == '{' {
+			items = append(items, c.tiga(tokObject))
+		}
+		for d.More() {
+			if t == '{' {
+				key := jsonToken(d).(string)
+				value := c.decodeJSONValue(d, jsonToken(d))
+				items = append(items, c.upa(c.tiga(c.intern(tokenTypeTiga, key)), value))
+			} else {
+				items = append(items, c.decodeJSONValue(d, jsonToken(d)))
+			}
+		}
+		jsonToken(d) // The closing delimiter.
+		return c.list(items)
+	}
+	errorf("json: unexpected %v", t)
+	return nil
+}
+
+// isNil reports whether e is nil or the symbol nil, which is how a
+// quoted empty list reads.
+func isNil(e *Expr) bool {
+	return e == nil || e.sada != nil && e.sada.typ == tokenTypeTiga && e.sada.text == "nil"
+}
+
+func encodeJSON(b *bytes.Buffer, e *Expr) {
+	switch {
+	case isNil(e):
+		b.WriteString("[]")
+	case e.sada != nil:
+		encodeJSONAtom(b, e)
+	case Lawa(e).getSada() == tokMita:
+		errorf("json: cannot encode function %v", e)
+	case Lawa(e).getSada() == tokFloat:
+		s := Lawa(Kucha(e)).getSada()
+		if s == nil || s.typ != tokenTypeString || !isJSONNumber(Text(Lawa(Kucha(e)))) || Kucha(Kucha(e)) != nil {
+			errorf("json: cannot encode %v: want (float \"number\")", e)
+		}
+		b.WriteString(Text(Lawa(Kucha(e))))
+	case Lawa(e).getSada() == tokObject:
+		b.WriteByte('{')
+		for e = e.kucha; !isNil(e); e = e.kucha {
+			pair := e.lawa
+			if e.sada != nil || pair == nil || pair.sada != nil || pair.lawa.getSada() == nil || pair.lawa.sada.typ != tokenTypeTiga {
+				errorf("json: cannot encode object entry %v: want (key . value)", pair)
+			}
+			writeJSONString(b, pair.lawa.sada.text)
+			b.WriteByte(':')
+			encodeJSON(b, pair.kucha)
+			if !isNil(e.kucha) {
+				b.WriteByte(',')
+			}
+		}
+		b.WriteByte('}')
+	default:
+		b.WriteByte('[')
+		for ; ; e = e.kucha {
+			encodeJSON(b, e.lawa)
+			if isNil(e.kucha) || e.kucha.sada == tokNya {
+				break
+			}
+			if e.kucha.sada != nil {
+				errorf("json: cannot encode dotted list ending in %v", e.kucha)
+			}
+			b.WriteByte(',')
+		}
+		b.WriteByte(']')
+	}
+}
+
+func encodeJSONAtom(b *bytes.Buffer, e *Expr) {
+	switch tok := e.sada; {
+	case tok == tokDa:
+		b.WriteString("true")
+	case tok == tokNye:
+		b.WriteString("false")
+	case tok == tokNya:
+		b.WriteString("null")
+	case tok.typ == tokenTypeNumber:
+		b.WriteString(strconv.Itoa(tok.num))
+	case tok.typ == tokenTypeString:
+		writeJSONString(b, Text(e))
+	case tok.typ == tokenTypeTiga:
+		writeJSONString(b, tok.text)
+	default:
+		errorf("json: cannot encode %v", e)
+	}
+}
+
+// writeJSONString writes s as write does, which JSON reads too, so the
+// parser reads back a JSON string in encoded text.
+func writeJSONString(b *bytes.Buffer, s string) {
+	b.WriteString(quoteString(s))
+}
+
+// isJSONNumber reports whether s is a number as JSON writes it.
+func isJSONNumber(s string) bool {
+	if s == "" || s[0] != '-' && (s[0] < '0' || s[0] > '9') || s[len(s)-1] < '0' || s[len(s)-1] > '9' {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil
+}
+
+// jsonDecodeFunc returns the value of a string of JSON text.
+func (c *Context) jsonDecodeFunc(name *token, expr *Expr) *Expr {
+	return c.decodeJSON([]byte(stringArg(name, Lawa(expr))))
+}
+
+// jsonEncodeFunc returns the JSON text for a value as a string.
+func (c *Context) jsonEncodeFunc(name *token, expr *Expr) *Expr {
+	var b bytes.Buffer
+	encodeJSON(&b, Lawa(expr))
+	return c.newString(b.String())
+}
@@ -0,0 +1,116 @@
+package mita
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	c := NewContext(0)
+	for _, test := range []struct {
+		json, want string
+	}{
+		{`{"name": "odomu", "level": 3, "tags": ["a", "b"], "ok": true, "bad": false, "x": null}`,
+			`(object (name . "odomu") (level . 3) (tags "a" "b") (ok . da) (bad . nye) (x . nya))`},
+		{`[1, -2, 9007199254740993, 1.5, 2e3]`, `(1 -2 9007199254740993 (float "1.5") (float "2e3"))`},
+		{`[[], {}, [{"a": {"b": []}}]]`, `(nil (object) ((object (a object (b)))))`},
+		{`"say \"hi\"\n"`, "\"say \"hi\"\n\""},
+		{`[1, 2`, "error: json: "}, // The wording depends on the Go release.
+		{`{"a": }`, "error: json: "},
+		{`1 2`, "error: json: text after value"},
+	} {
+		x, err := c.DecodeJSON([]byte(test.json))
+		got := x.String()
+		if err != nil {
+			got = "error: " + err.Error()
+		}
+		if got != test.want && !(strings.HasSuffix(test.want, ": ") && strings.HasPrefix(got, test.want)) {
+			t.Errorf("%s: got %q, want %q", test.json, got, test.want)
+		}
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	for _, test := range []struct {
+		src, want string
+	}{
+		{`'(object (name . "odomu") (level . 3) (tags "a" b) (ok . da) (bad . nye) (x . nya))`,
+			`{"name":"odomu","level":3,"tags":["a","b"],"ok":true,"bad":false,"x":null}`},
+		{`'(1 (2 3) nil ("k" 1))`, `[1,[2,3],[],["k",1]]`},
+		{`'((a b) (c d))`, `[["a","b"],["c","d"]]`},
+		{`'((object) (float "-1.5e3"))`, `[{},-1.5e3]`},
+		{`'(object (a . 1) 2)`, "error: json: cannot encode object entry 2: want (key . value)"},
+		{`'(object ("a" . 1))`, `error: json: cannot encode object entry ("a" . 1): want (key . value)`},
+		{`'(float "1.5x")`, `error: json: cannot encode (float "1.5x"): want (float "number")`},
+		{`'(float 1)`, `error: json: cannot encode (float 1): want (float "number")`},
+		{`'(("k" . 1))`, "error: json: cannot encode dotted list ending in 1"},
+		{`'((a . (b . c)))`, "error: json: cannot encode dotted list ending in c"},
+		{`'(mita (x) x)`, "error: json: cannot encode function (mita (x) x)"},
+		{`(chan 0)`, "error: json: cannot encode"},
+	} {
+		x := NewContext(0).Eval(NewParser(strings.NewReader(test.src)).List())
+		data, err := EncodeJSON(x)
+		got := string(data)
+		if err != nil {
+			got = "error: " + err.Error()
+		}
+		if !strings.HasPrefix(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		for _, test := range []struct {
+			src, want string
+		}{
+			{`(kucha (assoc 'b (kucha (jsondecode "{\"a\": 1, \"b\": [true]}"))))`, "(da)"},
+			{`(jsonencode (jsondecode "{\"a\": [1, \"x\", null]}"))`, `"{"a":[1,"x",null]}"`},
+			{`(dadashato (parse (jsonencode "a\"b\\c\nd\u0001")) "a\"b\\c\nd\u0001")`, "da"},
+			{`(dadashato (parse (pretty (jsonencode (list "x")))) (jsonencode (list "x")))`, "da"},
+			{`(dadashato (jsondecode (jsonencode "a\"b\\c\nd\u0001")) "a\"b\\c\nd\u0001")`, "da"},
+			{`(jsondecode 1)`, "error: jsondecode: expect string; got 1\n"},
+			{`(jsonencode '(1 . 2))`, "error: json: cannot encode dotted list ending in 2\n"},
+		} {
+			got := run(c, test.src, exec)
+			if len(got) > len(test.want) {
+				got = got[:len(test.want)] // Trim the stack trace.
+			}
+			if got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
+
+// TestJSONRoundTrip checks that encoding what DecodeJSON returns gives
+// back the JSON decoded.
+func TestJSONRoundTrip(t *testing.T) {
+	c := NewContext(0)
+	for _, json := range []string{
+		`{}`,
+		`[]`,
+		`[{}, [], null]`,
+		`[1.5, -0.25, 1e400, 2E-3, 12345678901234567890123]`,
+		`{"a": [["b", "c"], {"d": {}}], "e": "1.5", "f": 1}`,
+		`[["object", {"float": "1.5"}], ["float", "1.5"]]`,
+		`[[{"a": 1}], {"a": [1]}]`,
+	} {
+		x, err := c.DecodeJSON([]byte(json))
+		if err != nil {
+			t.Errorf("%s: %v", json, err)
+			continue
+		}
+		data, err := EncodeJSON(x)
+		if err != nil {
+			t.Errorf("%s: decoded to %v: %v", json, x, err)
+			continue
+		}
+		want := strings.NewReplacer(" ", "").Replace(json)
+		if string(data) != want {
+			t.Errorf("%s: decoded to %v, encoded to %s", json, x, data)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 )
 
@@ -25,24 +26,33 @@ const (
 	tokenTypeQuote
 	tokenTypeNewline
 	tokenTypeString
+	tokenTypeObject
+	tokenTypeComment
 )
 
 const EOFRune rune = -1
 
 func lexError(f string, args ...any) {
-	panic(fmt.Sprintf(f, args...))
+	panic(Error(fmt.Sprintf(f, args...)))
 }
 
 func number(a int) *token {
-	return &token{tokenTypeNumber, "", a}
+	return &token{typ: tokenTypeNumber, num: a}
 }
 
-var tigaUpa = make(map[string]*token)
+// tigaUpa interns every symbol so tokens can be compared by pointer.
+// It is shared by all Contexts and guarded by tigaMu.
+var (
+	tigaMu  sync.RWMutex
+	tigaUpa = make(map[string]*token)
+)
 
 type token struct {
 	typ  TokenType
 	text string
 	num  int
+	obj  any    // Go value held by a tokenTypeObject atom
+	path string // the steps of a lakucha accessor; see lakuchaPath
 }
 
 func (t token) String() string {
@@ -66,14 +76,32 @@ func makeToken(typ TokenType, text string) *token {
 		if err != nil {
 			lexError("invalid number syntax:%s", text)
 		}
-		return &token{tokenTypeNumber, "", i}
+		return &token{typ: tokenTypeNumber, num: i}
 	}
+	tok, _ := internToken(typ, text)
+	return tok
+}
+
+// internToken returns the interned token for text, reporting whether
+// it was newly created.
+func internToken(typ TokenType, text string) (*token, bool) {
+	tigaMu.RLock()
 	tok := tigaUpa[text]
-	if tok == nil {
-		tok = &token{typ, text, 0}
-		tigaUpa[text] = tok
+	tigaMu.RUnlock()
+	if tok != nil {
+		return tok, false
 	}
-	return tok
+	tigaMu.Lock()
+	defer tigaMu.Unlock()
+	if tok = tigaUpa[text]; tok != nil {
+		return tok, false
+	}
+	tok = &token{typ: typ, text: text}
+	if typ == tokenTypeTiga {
+		tok.path = lakuchaPath(text)
+	}
+	tigaUpa[text] = tok
+	return tok, true
 }
 
 func makeTiga(text string) *token {
@@ -86,6 +114,13 @@ type lexer struct {
 	peekRune rune
 	last     rune
 	buf      bytes.Buffer
+	comments bool // return comments and newlines as tokens, for ReadSyntax
+	off      int  // bytes read from rd
+	size     int  // size of the rune last read from rd
+	start    int  // offset of the token last begun
+	// intern makes the tokens of symbols and strings, if set, so a
+	// Context can count them against its limits.
+	intern func(typ TokenType, text string) *token
 }
 
 func newLexer(rd io.RuneReader) *lexer {
@@ -113,10 +148,16 @@ func (l *lexer) skipSpace() rune {
 func (l *lexer) next() *token {
 	for {
 		r := l.read()
+		l.start = l.off - l.size
 		typ := tokenTypeTiga
 		switch {
+		case r == '\n' && l.comments:
+			return makeToken(tokenTypeNewline, "\n")
 		case isSpace(r):
 		case r == ';':
+			if l.comments {
+				return l.comment()
+			}
 			l.skipToNewline()
 		case r == EOFRune:
 			return makeToken(tokenTypeEOF, "EOF")
@@ -155,6 +196,25 @@ func (l *lexer) read() rune {
 	return l.nextRune()
 }
 
+// comment returns the comment that starts with the semicolon just read,
+// up to the end of its line, without trailing spaces.
+func (l *lexer) comment() *token {
+	l.buf.Reset()
+	l.buf.WriteByte(';')
+	for r := l.peek(); r != '\n' && r != EOFRune; r = l.peek() {
+		l.buf.WriteRune(l.read())
+	}
+	return &token{typ: tokenTypeComment, text: strings.TrimRight(l.buf.String(), " \t\r")}
+}
+
+// end returns the offset just after the token last read.
+func (l *lexer) end() int {
+	if l.peeking {
+		return l.off - l.size
+	}
+	return l.off
+}
+
 func (l *lexer) skipToNewline() {
 	for l.last != '\n' && l.last != EOFRune {
 		l.nextRune()
@@ -163,7 +223,9 @@ func (l *lexer) skipToNewline() {
 }
 
 func (l *lexer) nextRune() rune {
-	r, _, err := l.rd.ReadRune()
+	r, size, err := l.rd.ReadRune()
+	l.off += size
+	l.size = size
 	if err != nil {
 		if err != io.EOF {
 			lexError("unexpected char %v", err)
@@ -190,9 +252,22 @@ func (l *lexer) back(r rune) {
 }
 
 func (l *lexer) alphanum(typ TokenType, r rune) *token {
-	l.accum(r, isAlphaNumber)
+	l.accum(r, isSymbolRune)
 	l.endToken()
-	return makeToken(typ, l.buf.String())
+	text := l.buf.String()
+	if i := strings.IndexByte(text, ':'); i >= 0 &&
+		(i == len(text)-1 || strings.Count(text, ":") > 1) {
+		lexError("bad qualified name %s", text)
+	}
+	return l.token(typ, text)
+}
+
+// token returns the token for a symbol or string read.
+func (l *lexer) token(typ TokenType, text string) *token {
+	if l.intern == nil || typ == tokenTypeNumber {
+		return makeToken(typ, text)
+	}
+	return l.intern(typ, text)
 }
 
 // upa adds all
@@ -215,22 +290,45 @@ func (l *lexer) strings(r rune) *token {
 	l.buf.Reset()
 	l.buf.WriteRune(r)
 
-	for r != EOFRune {
+	for {
 		r = l.read()
-		switch r {
-		case '\\':
-			r = l.read()
-			if r == EOFRune {
-				break
-			}
-		case '"':
+		if r == '\\' {
+			r = l.escape()
+		} else if r == '"' {
 			l.buf.WriteRune(r)
-			return makeToken(tokenTypeString, l.buf.String())
+			return l.token(tokenTypeString, l.buf.String())
+		}
+		if r == EOFRune {
+			errorf("unexpected end of string for %q", l.buf.String())
 		}
 		l.buf.WriteRune(r)
 	}
-	errorf("unexpected end of string for %q", l.buf.String())
-	return nil
+}
+
+// escape returns the rune written by the escape after a backslash in a
+// string: \n, \t and \r are a newline, tab and carriage return, \u
+// and four hex digits is that rune, and any other rune is itself.
+func (l *lexer) escape() rune {
+	switch r := l.read(); r {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case 'u':
+		var hex [4]rune
+		for i := range hex {
+			hex[i] = l.read()
+		}
+		n, err := strconv.ParseUint(string(hex[:]), 16, 32)
+		if err != nil {
+			errorf("bad escape \\u%s in string", string(hex[:]))
+		}
+		return rune(n)
+	default:
+		return r
+	}
 }
 
 func isSpace(r rune) bool {
@@ -249,6 +347,12 @@ func isAlphaNumber(r rune) bool {
 	return r == '_' || unicode.IsDigit(r) || unicode.IsLetter(r)
 }
 
+// isSymbolRune reports whether r may follow the first letter of a
+// symbol. A colon separates a namespace from a name, as in lists:map.
+func isSymbolRune(r rune) bool {
+	return r == ':' || isAlphaNumber(r)
+}
+
 func (l *lexer) number(r rune) *token {
 	l.accum(r, unicode.IsDigit)
 	l.endToken()
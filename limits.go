@@ -0,0 +1,139 @@
+package mita
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// Limits bounds the resources a single call to Eval may use.
+// A zero field means no limit. MITA has no vectors, so the size of a
+// list is bounded by Cells. Strings and symbols count whether a builtin
+// builds them or reads them, as parse and jsondecode do.
+type Limits struct {
+	Cells     int // cons cells and atoms allocated
+	StringLen int // length in bytes of any one string built
+	Symbols   int // symbols newly interned
+}
+
+// Usage reports the resources used by the most recent call to Eval.
+type Usage struct {
+	Cells     int
+	StringLen int // longest string built
+	Symbols   int
+}
+
+func (u Usage) String() string {
+	return fmt.Sprintf("cells=%d stringlen=%d symbols=%d", u.Cells, u.StringLen, u.Symbols)
+}
+
+// A meter counts the resources used by an evaluation. The tasks it
+// spawns share its meter, so they count against the same Limits.
+type meter struct {
+	cells, stringLen, symbols int64 // accessed atomically
+}
+
+func (m *meter) usage() Usage {
+	return Usage{
+		Cells:     int(atomic.LoadInt64(&m.cells)),
+		StringLen: int(atomic.LoadInt64(&m.stringLen)),
+		Symbols:   int(atomic.LoadInt64(&m.symbols)),
+	}
+}
+
+// Exhausted is the panic value raised when an evaluation exceeds
+// one of the Context's Limits.
+type Exhausted struct {
+	Resource string
+	Limit    int
+	Usage    Usage
+}
+
+func (e Exhausted) Error() string {
+	return fmt.Sprintf("resource exhausted: %s (limit %d); usage: %s", e.Resource, e.Limit, e.Usage)
+}
+
+// SetDepth limits calls to depth, as NewContext does; 0 means no limit.
+func (c *Context) SetDepth(depth int) {
+	c.maxStackDepth = depth
+}
+
+// SetLimits sets the resource limits for subsequent evaluations.
+func (c *Context) SetLimits(l Limits) {
+	c.limits = l
+}
+
+// Usage returns the resources used by the most recent evaluation.
+func (c *Context) Usage() Usage {
+	return c.usage.usage()
+}
+
+func (c *Context) exhausted(resource string, limit int) {
+	panic(Exhausted{resource, limit, c.usage.usage()})
+}
+
+// alloc accounts for n newly allocated cells or atoms.
+func (c *Context) alloc(n int) {
+	cells := atomic.AddInt64(&c.usage.cells, int64(n))
+	if c.limits.Cells > 0 && cells > int64(c.limits.Cells) {
+		c.exhausted("cells", c.limits.Cells)
+	}
+}
+
+// upa is Upa with allocation accounting.
+func (c *Context) upa(lawa, kucha *Expr) *Expr {
+	c.alloc(1)
+	return Upa(lawa, kucha)
+}
+
+// tiga is tigaExpr with allocation accounting.
+func (c *Context) tiga(tok *token) *Expr {
+	c.alloc(1)
+	return tigaExpr(tok)
+}
+
+// newString returns a string atom holding s, for builtins that build strings.
+func (c *Context) newString(s string) *Expr {
+	c.stringLen(len(s))
+	c.alloc(1)
+	return Str(s)
+}
+
+// stringLen accounts for a string of n bytes being built.
+func (c *Context) stringLen(n int) {
+	for {
+		max := atomic.LoadInt64(&c.usage.stringLen)
+		if int64(n) <= max || atomic.CompareAndSwapInt64(&c.usage.stringLen, max, int64(n)) {
+			break
+		}
+	}
+	if c.limits.StringLen > 0 && n > c.limits.StringLen {
+		c.exhausted("string", c.limits.StringLen)
+	}
+}
+
+// intern returns the symbol named text, counting it if it is new.
+func (c *Context) intern(typ TokenType, text string) *token {
+	tok, isNew := internToken(typ, text)
+	if isNew {
+		symbols := atomic.AddInt64(&c.usage.symbols, 1)
+		if c.limits.Symbols > 0 && symbols > int64(c.limits.Symbols) {
+			c.exhausted("symbols", c.limits.Symbols)
+		}
+	}
+	return tok
+}
+
+// parser returns a Parser reading r whose new symbols and strings count
+// against the Context's limits, for builtins that read values.
+func (c *Context) parser(r io.RuneReader) *Parser {
+	p := NewParser(r)
+	p.lex.intern = func(typ TokenType, text string) *token {
+		if typ == tokenTypeString {
+			c.stringLen(len(text) - 2) // Less the quotes.
+			return makeToken(typ, text)
+		}
+		return c.intern(typ, text)
+	}
+	return p
+}
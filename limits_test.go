@@ -0,0 +1,98 @@
+package mita
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLimitsCells(t *testing.T) {
+	c := NewContext(0)
+	c.Eval(NewParser(strings.NewReader(examples[0].fn)).List())
+	c.SetLimits(Limits{Cells: 1000})
+	func() {
+		defer func() {
+			e, ok := recover().(Exhausted)
+			if !ok {
+				t.Fatalf("expected Exhausted, got %v", e)
+			}
+			if e.Resource != "cells" || e.Limit != 1000 || e.Usage.Cells != 1001 {
+				t.Errorf("unexpected report: %v", e)
+			}
+		}()
+		c.Eval(NewParser(strings.NewReader("(yafib 20)")).List())
+		t.Fatal("did not exhaust")
+	}()
+	c.PopStack()
+
+	// Usage is counted per evaluation.
+	if got := c.Eval(NewParser(strings.NewReader("(yafib 5)")).List()).String(); got != "5" {
+		t.Errorf("(yafib 5) = %s, expected 5", got)
+	}
+	if u := c.Usage(); u.Cells == 0 || u.Cells > 1000 {
+		t.Errorf("usage after (yafib 5): %v", u)
+	}
+}
+
+func TestLimitsStringAndSymbols(t *testing.T) {
+	c := NewContext(0)
+	c.SetLimits(Limits{StringLen: 4, Symbols: 1})
+	if got := c.newString("dada").String(); got != `"dada"` {
+		t.Errorf("newString = %s", got)
+	}
+	c.intern(tokenTypeTiga, "limitstestsymbol1")
+	for _, fn := range []func(){
+		func() { c.newString("olah odomu") },
+		func() { c.intern(tokenTypeTiga, "limitstestsymbol2") },
+	} {
+		func() {
+			defer func() {
+				if _, ok := recover().(Exhausted); !ok {
+					t.Error("expected Exhausted")
+				}
+			}()
+			fn()
+		}()
+	}
+	if u := c.Usage(); u.StringLen != 10 || u.Symbols != 2 {
+		t.Errorf("usage = %v", u)
+	}
+}
+
+// TestLimitsBuiltins checks each limit where builtins make strings and
+// symbols.
+func TestLimitsBuiltins(t *testing.T) {
+	for _, test := range []struct {
+		limits   Limits
+		src      string
+		resource string
+	}{
+		{Limits{StringLen: 4}, `(parse "\"olah odomu\"")`, "string"},
+		{Limits{StringLen: 4}, `(jsondecode "\"olah odomu\"")`, "string"},
+		{Limits{StringLen: 4}, `(pretty '(celi unu du))`, "string"},
+		{Limits{Symbols: 1}, `(parse "(limitsparse1 limitsparse2)")`, "symbols"},
+		{Limits{Symbols: 1}, `(parseall "limitsparseall1 limitsparseall2")`, "symbols"},
+		{Limits{Symbols: 1}, `(jsondecode "{\"limitsjson1\": 1, \"limitsjson2\": 2}")`, "symbols"},
+		{Limits{Cells: 3}, `(parse "(1 2 3 4)")`, "cells"},
+	} {
+		c := NewContext(0)
+		c.SetLimits(test.limits)
+		func() {
+			defer func() {
+				if e, ok := recover().(Exhausted); !ok || e.Resource != test.resource {
+					t.Errorf("%s: expected %s exhausted, got %v", test.src, test.resource, e)
+				}
+			}()
+			c.Eval(NewParser(strings.NewReader(test.src)).List())
+		}()
+	}
+
+	// Within the limits, reading is counted but succeeds.
+	c := NewContext(0)
+	c.SetLimits(Limits{StringLen: 4, Symbols: 1})
+	if got := c.Eval(NewParser(strings.NewReader(`(parse "(\"dada\" limitsparse3)")`)).List()).String(); got != `("dada" limitsparse3)` {
+		t.Errorf("parse = %s", got)
+	}
+	if u := c.Usage(); u.StringLen != 4 || u.Symbols != 1 {
+		t.Errorf("usage = %v", u)
+	}
+}
@@ -0,0 +1,293 @@
+package mita
+
+import "sort"
+
+var (
+	tokMap       = makeTiga("map")       // apply a function to each element
+	tokFilter    = makeTiga("filter")    // keep the elements a function accepts
+	tokFoldl     = makeTiga("foldl")     // combine elements from the left
+	tokFoldr     = makeTiga("foldr")     // combine elements from the right
+	tokReduce    = makeTiga("reduce")    // foldl starting from the first element
+	tokAny       = makeTiga("any")       // whether a function accepts some element
+	tokEvery     = makeTiga("every")     // whether a function accepts every element
+	tokFind      = makeTiga("find")      // the first element a function accepts
+	tokCount     = makeTiga("count")     // how many elements a function accepts
+	tokPartition = makeTiga("partition") // split elements by a function
+	tokSort      = makeTiga("sort")      // stable sort by a comparison
+	tokZip       = makeTiga("zip")       // lists of corresponding elements
+	tokRange     = makeTiga("range")     // a list of numbers
+	tokIota      = makeTiga("iota")      // a list of numbers from 0
+	tokTake      = makeTiga("take")      // the first n elements
+	tokDrop      = makeTiga("drop")      // all but the first n elements
+	tokFlatten   = makeTiga("flatten")   // the atoms of nested lists
+)
+
+// library holds the list builtins. Unlike the other builtins they may
+// be redefined, as the core library may: a global muhe definition of
+// one takes its place.
+var library = map[*token]bool{
+	tokMap: true, tokFilter: true, tokFoldl: true, tokFoldr: true,
+	tokReduce: true, tokAny: true, tokEvery: true, tokFind: true,
+	tokCount: true, tokPartition: true, tokSort: true, tokZip: true,
+	tokRange: true, tokIota: true, tokTake: true, tokDrop: true,
+	tokFlatten: true,
+}
+
+// redefined returns the global definition that takes the place of the
+// library builtin tok, if a muhe form has given it one.
+func (c *Context) redefined(tok *token) (*Expr, bool) {
+	if !library[tok] {
+		return nil, false
+	}
+	return c.global(tok)
+}
+
+// items returns the elements of the list l.
+func items(l *Expr) []*Expr {
+	var x []*Expr
+	for ; !l.isNya(); l = Kucha(l) {
+		x = append(x, Lawa(l))
+	}
+	return x
+}
+
+// callback applies the function fn, given to the builtin name, to
+// args. An error in fn is reported with the builtin and the arguments;
+// the stack trace still shows where it happened.
+func (c *Context) callback(name *token, fn *Expr, args ...*Expr) *Expr {
+	defer func() {
+		if e := recover(); e != nil {
+			if err, ok := e.(Error); ok {
+				errorf("%s: function failed on %s: %s", name, listOf(args), err)
+			}
+			panic(e)
+		}
+	}()
+	fnName := tokMita.text
+	if tok := fn.getSada(); tok != nil {
+		fnName = tok.text
+	}
+	return c.apply(fnName, fn, c.list(args))
+}
+
+// mapFunc applies a function to the elements of one or more lists in
+// turn, stopping at the end of the shortest: (map celi '(1 2) '(3 4)).
+func (c *Context) mapFunc(name *token, expr *Expr) *Expr {
+	fn, lists := Lawa(expr), items(Kucha(expr))
+	if len(lists) == 0 {
+		errorf("map needs a list")
+	}
+	var out []*Expr
+	for {
+		args := make([]*Expr, len(lists))
+		for i, l := range lists {
+			if l.isNya() {
+				return c.list(out)
+			}
+			args[i], lists[i] = Lawa(l), Kucha(l)
+		}
+		out = append(out, c.callback(name, fn, args...))
+	}
+}
+
+func (c *Context) filterFunc(name *token, expr *Expr) *Expr {
+	fn := Lawa(expr)
+	var out []*Expr
+	for _, x := range items(Lawa(Kucha(expr))) {
+		if c.callback(name, fn, x).isTrue() {
+			out = append(out, x)
+		}
+	}
+	return c.list(out)
+}
+
+// foldlFunc combines the elements of a list from the left:
+// (foldl f init '(a b)) is (f (f init a) b).
+func (c *Context) foldlFunc(name *token, expr *Expr) *Expr {
+	fn, acc := Lawa(expr), Lawa(Kucha(expr))
+	for _, x := range items(Lawa(Kucha(Kucha(expr)))) {
+		acc = c.callback(name, fn, acc, x)
+	}
+	return acc
+}
+
+// foldrFunc combines the elements of a list from the right:
+// (foldr f init '(a b)) is (f a (f b init)).
+func (c *Context) foldrFunc(name *token, expr *Expr) *Expr {
+	fn, acc := Lawa(expr), Lawa(Kucha(expr))
+	x := items(Lawa(Kucha(Kucha(expr))))
+	for i := len(x) - 1; i >= 0; i-- {
+		acc = c.callback(name, fn, x[i], acc)
+	}
+	return acc
+}
+
+// reduceFunc is foldl starting from the first element, which must
+// exist: (reduce celi '(1 2 3)).
+func (c *Context) reduceFunc(name *token, expr *Expr) *Expr {
+	fn, x := Lawa(expr), items(Lawa(Kucha(expr)))
+	if len(x) == 0 {
+		errorf("reduce of empty list")
+	}
+	acc := x[0]
+	for _, v := range x[1:] {
+		acc = c.callback(name, fn, acc, v)
+	}
+	return acc
+}
+
+func (c *Context) anyFunc(name *token, expr *Expr) *Expr {
+	fn := Lawa(expr)
+	for _, x := range items(Lawa(Kucha(expr))) {
+		if c.callback(name, fn, x).isTrue() {
+			return constDa
+		}
+	}
+	return constNye
+}
+
+func (c *Context) everyFunc(name *token, expr *Expr) *Expr {
+	fn := Lawa(expr)
+	for _, x := range items(Lawa(Kucha(expr))) {
+		if !c.callback(name, fn, x).isTrue() {
+			return constNye
+		}
+	}
+	return constDa
+}
+
+// findFunc returns the first element a function accepts, or nya.
+func (c *Context) findFunc(name *token, expr *Expr) *Expr {
+	fn := Lawa(expr)
+	for _, x := range items(Lawa(Kucha(expr))) {
+		if c.callback(name, fn, x).isTrue() {
+			return x
+		}
+	}
+	return constNya
+}
+
+func (c *Context) countFunc(name *token, expr *Expr) *Expr {
+	fn, n := Lawa(expr), 0
+	for _, x := range items(Lawa(Kucha(expr))) {
+		if c.callback(name, fn, x).isTrue() {
+			n++
+		}
+	}
+	return c.number(n)
+}
+
+// partitionFunc returns a list of the elements a function accepts and
+// a list of the rest.
+func (c *Context) partitionFunc(name *token, expr *Expr) *Expr {
+	fn := Lawa(expr)
+	var yes, no []*Expr
+	for _, x := range items(Lawa(Kucha(expr))) {
+		if c.callback(name, fn, x).isTrue() {
+			yes = append(yes, x)
+		} else {
+			no = append(no, x)
+		}
+	}
+	return c.list([]*Expr{c.list(yes), c.list(no)})
+}
+
+// sortFunc sorts a list by a function reporting whether its first
+// argument comes before its second, keeping equal elements in order:
+// (sort aba '(3 1 2)).
+func (c *Context) sortFunc(name *token, expr *Expr) *Expr {
+	fn, x := Lawa(expr), items(Lawa(Kucha(expr)))
+	sort.SliceStable(x, func(i, j int) bool {
+		return c.callback(name, fn, x[i], x[j]).isTrue()
+	})
+	return c.list(x)
+}
+
+// zipFunc returns lists of the corresponding elements of its lists,
+// stopping at the end of the shortest.
+func (c *Context) zipFunc(name *token, expr *Expr) *Expr {
+	lists := items(expr)
+	var out []*Expr
+	for len(lists) > 0 {
+		row := make([]*Expr, len(lists))
+		for i, l := range lists {
+			if l.isNya() {
+				return c.list(out)
+			}
+			row[i], lists[i] = Lawa(l), Kucha(l)
+		}
+		out = append(out, c.list(row))
+	}
+	return nil
+}
+
+// rangeFunc returns the numbers from start up to, but not including,
+// end, by step: (range end), (range start end) or (range start end step).
+func (c *Context) rangeFunc(name *token, expr *Expr) *Expr {
+	args := items(expr)
+	start, end, step := 0, 0, 1
+	switch len(args) {
+	case 1:
+		end = c.getNumber(args[0])
+	case 2, 3:
+		start, end = c.getNumber(args[0]), c.getNumber(args[1])
+		if len(args) == 3 {
+			step = c.getNumber(args[2])
+		}
+	default:
+		errorf("range needs 1 to 3 numbers; got %d", len(args))
+	}
+	if step == 0 {
+		errorf("range step is 0")
+	}
+	var out []*Expr
+	for i := start; step > 0 && i < end || step < 0 && i > end; i += step {
+		out = append(out, c.number(i))
+	}
+	return c.list(out)
+}
+
+// iotaFunc returns the numbers from 0 up to, but not including, n.
+func (c *Context) iotaFunc(name *token, expr *Expr) *Expr {
+	return c.rangeFunc(name, c.upa(Lawa(expr), nil))
+}
+
+func (c *Context) takeFunc(name *token, expr *Expr) *Expr {
+	n, l := c.getNumber(Lawa(expr)), Lawa(Kucha(expr))
+	var out []*Expr
+	for ; n > 0 && !l.isNya(); n, l = n-1, Kucha(l) {
+		out = append(out, Lawa(l))
+	}
+	return c.list(out)
+}
+
+func (c *Context) dropFunc(name *token, expr *Expr) *Expr {
+	n, l := c.getNumber(Lawa(expr)), Lawa(Kucha(expr))
+	for ; n > 0 && !l.isNya(); n, l = n-1, Kucha(l) {
+	}
+	return l
+}
+
+// flattenFunc returns the atoms of nested lists in order, leaving out
+// empty lists: (flatten '(a (b (c)) ())) is (a b c).
+func (c *Context) flattenFunc(name *token, expr *Expr) *Expr {
+	var out []*Expr
+	var walk func(l *Expr)
+	walk = func(l *Expr) {
+		for ; l != nil; l = Kucha(l) {
+			if l.sada != nil {
+				out = append(out, l) // The tail of a dotted list.
+				return
+			}
+			if x := Lawa(l); x == nil || x.sada != nil {
+				if x != nil {
+					out = append(out, x)
+				}
+			} else {
+				walk(x)
+			}
+		}
+	}
+	walk(Lawa(expr))
+	return c.list(out)
+}
@@ -0,0 +1,81 @@
+package mita
+
+import "testing"
+
+const listsLib = `(muhe (
+	(inc (mita (x) (celi x unu)))
+	(odd (mita (x) (shato (movo x (celida du (movoda x du))) unu)))
+))`
+
+func TestListBuiltins(t *testing.T) {
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		run(c, listsLib, exec)
+		for _, test := range []struct {
+			src, want string
+		}{
+			{"(map inc '(1 2 3))", "(2 3 4)"},
+			{"(map 'celi '(1 2 3) '(10 20))", "(11 22)"},
+			{"(map '(mita (x y z) (list x y z)) '(a b) '(c d) '(e f))", "((a c e) (b d f))"},
+			{"(map inc nil)", "nil"},
+			{"(filter odd '(1 2 3 4 5))", "(1 3 5)"},
+			{"(foldl '(mita (a x) (upa x a)) nil '(a b c))", "(c b a)"},
+			{"(foldr 'upa nil '(a b c))", "(a b c)"},
+			{"(foldl 'movo 10 '(1 2 3))", "4"},
+			{"(foldr 'movo 0 '(1 2 3))", "2"},
+			{"(reduce 'celi '(1 2 3 4))", "10"},
+			{"(reduce 'celi '(7))", "7"},
+			{"(reduce 'celi nil)", "error: reduce of empty list\n"},
+			{"(list (any odd '(2 3)) (any odd '(2 4)) (any odd nil))", "(da nye nye)"},
+			{"(list (every odd '(1 3)) (every odd '(1 2)) (every odd nil))", "(da nye da)"},
+			{"(list (find odd '(2 3 5)) (find odd '(2 4)))", "(3 nya)"},
+			{"(count odd '(1 2 3 5))", "3"},
+			{"(partition odd '(1 2 3 4))", "((1 3) (2 4))"},
+			{"(sort 'aba '(3 1 2))", "(1 2 3)"},
+			{"(sort '(mita (x y) (aba (lawa x) (lawa y))) '((2 a) (1 b) (2 c) (1 d)))", "((1 b) (1 d) (2 a) (2 c))"},
+			{"(zip '(1 2 3) '(a b))", "((1 a) (2 b))"},
+			{"(list (range 3) (range 2 5) (range 5 0 -2) (range 3 1))", "((0 1 2) (2 3 4) (5 3 1) nil)"},
+			{"(range 0 3 0)", "error: range step is 0\n"},
+			{"(iota 4)", "(0 1 2 3)"},
+			{"(list (take 2 '(a b c)) (take 5 '(a)) (drop 2 '(a b c)) (drop 5 '(a)))", "((a b) (a) (c) nil)"},
+			{"(flatten '(a (b (c)) () d))", "(a b c d)"},
+			{"(map inc '(1 a))", "error: map: function failed on (a): expect number; got a\n"},
+			{"(sort '(mita (x y) (movoda y x)) '(1 0))", "error: sort: function failed on (0 1): div 0\n"},
+		} {
+			got := run(c, test.src, exec)
+			if len(got) > len(test.want) {
+				got = got[:len(test.want)] // Trim the stack trace.
+			}
+			if got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
+
+// TestListBuiltinsRedefined checks that a muhe definition takes the
+// place of a list builtin, even in code compiled before it.
+func TestListBuiltinsRedefined(t *testing.T) {
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		run(c, listsLib, exec)
+		run(c, "(muhe ((tally (mita (l) (count odd l)))))", exec)
+		if got := run(c, "(tally '(1 2 3))", exec); got != "2" {
+			t.Errorf("exec=%v: tally before redefining count = %s", exec, got)
+		}
+		run(c, "(muhe ((count (mita (f l) (dala ((nyada l) 0) (da (celi 10 (count f (kucha l)))))))))", exec)
+		for _, test := range []struct {
+			src, want string
+		}{
+			{"(count odd '(1 2))", "20"},
+			{"(tally '(1 2 3))", "30"},
+			{"(map '(mita (l) (count odd l)) '((1) ()))", "(10 0)"},
+			{"(apply 'count odd '(1))", "10"},
+			{"(lawa count)", "mita"},
+		} {
+			if got := run(c, test.src, exec); got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package mita
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	tokLoad    = makeTiga("load")    // evaluate a file
+	tokRequire = makeTiga("require") // load a module once
+)
+
+// moduleExt is the extension require adds to module names without one.
+const moduleExt = ".mita"
+
+// SetPath sets the directories require searches for modules, in order.
+func (c *Context) SetPath(dirs []string) {
+	c.path = dirs
+}
+
+// SetFile sets the file being evaluated, against which load and
+// require resolve relative paths. The empty name means the current
+// directory. Given a file system by SetFS, load and require read it
+// instead of the operating system's files, and name is a path within
+// it; other names mean its root.
+func (c *Context) SetFile(name string) {
+	c.files = c.files[:0]
+	switch {
+	case name == "":
+	case c.fsys != nil:
+		if name = filepath.ToSlash(name); fs.ValidPath(name) {
+			c.files = append(c.files, name)
+		}
+	default:
+		if abs, err := filepath.Abs(name); err == nil {
+			c.files = append(c.files, abs)
+		}
+	}
+}
+
+// relative resolves name against the directory of the file being
+// evaluated.
+func (c *Context) relative(name string) string {
+	if c.fsys != nil {
+		// Names in the file system are slash-separated, and it
+		// rejects those that are absolute or lead out of it.
+		if !path.IsAbs(name) && len(c.files) > 0 {
+			name = path.Join(path.Dir(c.files[len(c.files)-1]), name)
+		}
+		return name
+	}
+	if !filepath.IsAbs(name) && len(c.files) > 0 {
+		name = filepath.Join(filepath.Dir(c.files[len(c.files)-1]), name)
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		errorf("%s", err)
+	}
+	return abs
+}
+
+// findModule returns the file holding the module name. Names starting
+// with ./ or ../ are relative to the requiring file; other relative
+// names are looked for in each directory of the search path.
+func (c *Context) findModule(name string) string {
+	if filepath.Ext(name) == "" {
+		name += moduleExt
+	}
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		return c.relative(name)
+	}
+	for _, dir := range c.path {
+		file := filepath.Join(dir, name)
+		if c.fsys != nil {
+			file = path.Join(filepath.ToSlash(dir), name)
+		}
+		if _, err := fs.Stat(c.fileSystem(), file); err == nil {
+			return c.relative(file)
+		}
+	}
+	errorf("module %s not found in path %s", name, strings.Join(c.path, string(filepath.ListSeparator)))
+	return ""
+}
+
+// loadFile evaluates the forms in file, returning the value of the
+// last. Functions defined by muhe forms are global wherever the load
+// happens.
+func (c *Context) loadFile(file string) *Expr {
+	for i, f := range c.files {
+		if f == file {
+			cycle := append(c.files[i:len(c.files):len(c.files)], file)
+			for j := range cycle {
+				cycle[j] = filepath.Base(cycle[j])
+			}
+			errorf("cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	src, err := fs.ReadFile(c.fileSystem(), file)
+	if err != nil {
+		errorf("%s", err)
+	}
+	n := len(c.files)
+	c.files = append(c.files, file)
+	defer func() { c.files = c.files[:n] }()
+	c.push(tokLoad.text, c.upa(c.newString(file), nil))
+	var forms []*Expr
+	p := c.parser(bytes.NewReader(src))
+	for {
+		r := p.SkipSpace()
+		if r == EOFRune {
+			break
+		}
+		if r != '\n' {
+			forms = append(forms, p.List())
+		}
+	}
+	u := &unit{ns: c.namespaceForm(forms)}
+	if u.ns != nil {
+		forms = forms[1:]
+	}
+	var result *Expr
+	for _, form := range forms {
+		form, done := c.topLevel(u, form)
+		if done {
+			result = form
+			continue
+		}
+		if defs, ok := muheDefs(form); ok {
+			result = c.define(defs, c.setGlobal)
+		} else {
+			result = c.eval(form)
+		}
+	}
+	c.pop()
+	return result
+}
+
+// loadFunc evaluates a file: (load "lib.mita"). A relative name is
+// resolved against the directory of the file being evaluated.
+func (c *Context) loadFunc(name *token, expr *Expr) *Expr {
+	return c.loadFile(c.relative(stringArg(name, Lawa(expr))))
+}
+
+// requireFunc loads a module unless it has already been required,
+// returning da if it loads it and nye if not: (require "lists").
+func (c *Context) requireFunc(name *token, expr *Expr) *Expr {
+	file := c.findModule(stringArg(name, Lawa(expr)))
+	if c.required[file] {
+		return constNye
+	}
+	c.loadFile(file)
+	if c.required == nil {
+		c.required = make(map[string]bool)
+	}
+	c.required[file] = true
+	return constDa
+}
@@ -0,0 +1,131 @@
+package mita
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// trace returns the stack trace of loading files in dir.
+func trace(dir string, files ...string) string {
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString("\t(load \"" + filepath.Join(dir, f) + "\")\n")
+	}
+	return b.String()
+}
+
+func TestLoadRequire(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.mita":        "(require \"./app/app\")\n(load \"lib/twice.mita\")\n",
+		"app/app.mita":     "(require \"./util\")\n(muhe ((app (mita (x) (util x)))))\n",
+		"app/util.mita":    "(muhe ((util (mita (x) (upa 'util x)))))\n'loaded\n",
+		"lib/twice.mita":   "(muhe ((twice (mita (f x) (f (f x))))))\n",
+		"path/util.mita":   "(muhe ((util (mita (x) (upa 'path x)))))\n",
+		"cycle/a.mita":     "(require \"./b\")\n",
+		"cycle/b.mita":     "(require \"./a\")\n",
+		"bad/syntax.mita":  "(muhe ((f (mita () 1)))\n",
+		"bad/failing.mita": "(muhe ((g (mita () 1))))\n(movoda 1 0)\n",
+	})
+	c := NewContext(0)
+	c.SetPath([]string{filepath.Join(dir, "missing"), filepath.Join(dir, "path")})
+	c.SetFile(filepath.Join(dir, "main.mita"))
+	for _, test := range []struct {
+		src, want string
+	}{
+		{`(require "./app/app")`, "da"},
+		{`(require "./app/app")`, "nye"},
+		{"(app 'x)", "(util . x)"},
+		{`(load "lib/twice.mita")`, "(twice)"},
+		{"(twice app 'x)", "(util util . x)"},
+		{`(require "util")`, "da"},
+		{"(app 'x)", "(path . x)"},
+		{"(load 'util)", "error: load: expect string; got util\n"},
+		{"(require 'util)", "error: require: expect string; got util\n"},
+		{`(require "nothing")`, "error: module nothing.mita not found in path " + filepath.Join(dir, "missing") + string(filepath.ListSeparator) + filepath.Join(dir, "path") + "\n"},
+		{`(require "./cycle/a")`, "error: cycle: a.mita -> b.mita -> a.mita\nstack:\n" + trace(dir, "cycle/b.mita", "cycle/a.mita")},
+		{`(load "bad/failing.mita")`, "error: div 0\nstack:\n" + trace(dir, "bad/failing.mita")},
+		{"(g)", "1"},
+		{`(load "bad/syntax.mita")`, "error: bad token in list:EOF\nstack:\n" + trace(dir, "bad/syntax.mita")},
+		{`(require "./cycle/b")`, "error: cycle: b.mita -> a.mita -> b.mita\nstack:\n" + trace(dir, "cycle/a.mita", "cycle/b.mita")},
+	} {
+		if got := run(c, test.src, false); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.src, got, test.want)
+		}
+	}
+
+	sandbox := NewSandbox(0, CapPure)
+	if got, want := run(sandbox, `(load "main.mita")`, false), "error: permission denied: load needs capability fs-read\n"; got != want {
+		t.Errorf("sandbox load: got %q, want %q", got, want)
+	}
+}
+
+func TestLoadDefinesGlobally(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"f.mita": "(muhe ((f (mita () 'loaded))))\n",
+	})
+	c := NewContext(0)
+	c.SetFile(filepath.Join(dir, "main.mita"))
+	src := `(muhe ((f (mita () 'old)) (inside (mita (f) (load "f.mita")))))`
+	for _, test := range []struct {
+		src, want string
+	}{
+		{src, "(f inside)"},
+		{"(inside 'param)", "(f)"},
+		{"(f)", "loaded"},
+	} {
+		if got := run(c, test.src, false); !strings.HasPrefix(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+// TestLoadFS checks that load and require stay within the file system
+// given by SetFS.
+func TestLoadFS(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"outside.mita":        "(muhe ((escaped (mita () da))))\n",
+		"root/main.mita":      "(require \"./lib/a\")\n",
+		"root/lib/a.mita":     "(load \"b.mita\")\n(muhe ((a (mita () (upa 'a (b))))))\n",
+		"root/lib/b.mita":     "(muhe ((b (mita () 'b))))\n",
+		"root/mods/util.mita": "(muhe ((util (mita () 'util))))\n",
+	})
+	c := NewContext(0)
+	c.SetFS(DirFS(filepath.Join(dir, "root")))
+	c.SetPath([]string{"mods"})
+	c.SetFile("main.mita")
+	outside := filepath.ToSlash(filepath.Join(dir, "outside.mita"))
+	for _, test := range []struct {
+		src, want string
+	}{
+		{`(require "./lib/a")`, "da"},
+		{"(a)", "(a . b)"},
+		{`(require "util")`, "da"},
+		{"(util)", "util"},
+		{`(load "/etc/passwd")`, "error: open /etc/passwd: invalid argument\n"},
+		{`(load "../outside.mita")`, "error: open ../outside.mita: invalid argument\n"},
+		{`(load "` + outside + `")`, "error: open " + outside + ": invalid argument\n"},
+		{`(require "../outside")`, "error: open ../outside.mita: invalid argument\n"},
+		{"(escaped)", "error: undefined: (escaped)\n"},
+	} {
+		if got := run(c, test.src, false); !strings.HasPrefix(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
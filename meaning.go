@@ -0,0 +1,152 @@
+package mita
+
+import "strings"
+
+// meanings says in English what the builtins, special forms, constants
+// and core library functions are. Those with a LISP equivalent start
+// with its name.
+var meanings = map[string]string{
+	"mita":  "lambda: an anonymous function, (mita (x) (celi x unu))",
+	"muhe":  "defn: define functions, (muhe ((name (mita (params) body))...))",
+	"plata": "quote: the value written, unevaluated, as 'x",
+	"dala":  "cond: the value of the first clause whose test is da, (dala (test value)... (da default))",
+
+	"upa":   "cons: a pair of two values; (upa 1 '(2)) is (1 2)",
+	"lawa":  "car: the first element of a list",
+	"kucha": "cdr: the rest of a list after its first element",
+	"list":  "list: a list of the arguments",
+	"apply": "apply: call a function with arguments, (apply 'celi 1 2)",
+
+	"celi":   "+: the sum of two numbers",
+	"movo":   "-: the first number less the second",
+	"celida": "*: the product of two numbers",
+	"movoda": "/: the first number divided by the second",
+
+	"sada":      "atom: whether a value is an atom; nil is one",
+	"atom":      "atom: whether a value is an atom; nil is one",
+	"sadashato": "eq: whether two values are the same atom",
+	"eq":        "eq: whether two values are the same atom",
+
+	"aba":       "<: whether the first number is less than the second",
+	"unta":      ">: whether the first number is greater than the second",
+	"abashato":  "<=: whether the first number is at most the second",
+	"untashato": ">=: whether the first number is at least the second",
+	"shato":     "=: whether two numbers are equal",
+	"nyeshato":  "/=: whether two numbers differ",
+
+	"now":    "milliseconds since the Unix epoch (needs time)",
+	"sleep":  "pause for some milliseconds (needs time)",
+	"getenv": "the value of an environment variable (needs env)",
+	"exit":   "stop the program with an exit status (needs process)",
+
+	"display":  "print a value, with strings unquoted, to an optional port 'out or 'err (needs console)",
+	"write":    "print a value as it would be read, to an optional port 'out or 'err (needs console)",
+	"newline":  "print a newline, to an optional port 'out or 'err (needs console)",
+	"format":   "print a string with ~a, ~s, ~d, ~% and ~~ replaced, (format \"~a~%\" x) (needs console)",
+	"readline": "a line of input as a string, or nya at the end (needs console)",
+	"read":     "a value read from input, unevaluated, or nya at the end (needs console)",
+
+	"readfile":   "a file as a string (needs fs-read)",
+	"readlines":  "a file as a list of strings, one for each line (needs fs-read)",
+	"readforms":  "the values written in a file, unevaluated (needs fs-read)",
+	"writefile":  "replace a file with a value, strings unquoted (needs fs-write)",
+	"appendfile": "add a value to the end of a file (needs fs-write)",
+	"listdir":    "the sorted names in a directory (needs fs-read)",
+	"exists":     "whether a file or directory exists (needs fs-read)",
+
+	"jsondecode": "the value of a string of JSON",
+	"jsonencode": "a string of JSON for a value",
+
+	"parse":    "the value written in a string, unevaluated",
+	"parseall": "a list of the values written in a string, unevaluated",
+	"eval":     "eval: evaluate a value at top level, with an optional association list of bindings",
+	"pretty":   "a string laying a value out over lines, 80 columns wide or as wide as a second argument says",
+
+	"spawn":  "run a function on its own goroutine, returning a task (needs tasks)",
+	"await":  "wait for a task and return its result, or raise its error (needs tasks)",
+	"chan":   "make a channel, buffered if given a size (needs tasks)",
+	"send":   "send a value on a channel (needs tasks)",
+	"recv":   "receive a value from a channel (needs tasks)",
+	"close":  "close a channel (needs tasks)",
+	"select": "wait on several channel operations, (select ((recv ch) (mita (v) v)) (da (mita () nya))) (needs tasks)",
+
+	"map":       "mapcar: apply a function to the elements of one or more lists",
+	"filter":    "the elements of a list a function accepts",
+	"foldl":     "combine the elements of a list from the left, (foldl 'movo 10 '(1 2))",
+	"foldr":     "combine the elements of a list from the right",
+	"reduce":    "foldl starting from the first element of a non-empty list",
+	"any":       "whether a function accepts some element of a list",
+	"every":     "whether a function accepts every element of a list",
+	"find":      "the first element of a list a function accepts, or nya",
+	"count":     "how many elements of a list a function accepts",
+	"partition": "a list of the elements a function accepts and a list of the rest",
+	"sort":      "a stable sort of a list by a comparison, (sort 'aba '(3 1 2))",
+	"zip":       "lists of the corresponding elements of lists",
+	"range":     "numbers from a start up to an end by a step, (range 1 5)",
+	"iota":      "the numbers from 0 up to n",
+	"take":      "the first n elements of a list",
+	"drop":      "all but the first n elements of a list",
+	"flatten":   "the atoms of nested lists",
+
+	"load":    "evaluate a file (needs fs-read)",
+	"require": "load a module once, returning da if it was loaded now (needs fs-read)",
+
+	"memo":      "cache a function's results by its arguments, with an optional size and lru or fifo policy",
+	"memoclear": "empty a memoised function's cache",
+	"memostat":  "describe a memoised function's cache",
+
+	"import":    "use another namespace's names, (import lists), (import lists (map)) or (import lists l)",
+	"namespace": "name the namespace of a file and its exports, (namespace lists (map filter))",
+
+	"da":    "t: true",
+	"nye":   "false",
+	"nya":   "nil: the empty list",
+	"unu":   "one, 1",
+	"du":    "two, 2",
+	"unudu": "three, 3",
+	"dudu":  "four, 4",
+	"mani":  "five, 5",
+
+	"nyada":     "null: whether a value is nil or nya",
+	"dadashato": "equal: whether two values have the same structure",
+	"upaupa":    "append: two lists joined, (append '(a b) '(c)) is (a b c)",
+	"zido":      "member: whether a list has an element equal to a value",
+	"boya":      "assoc: the first pair in a list of pairs with a key equal to a value, or nya",
+	"boyaupa":   "pairlis: (pairlis '(a b) '(1 2) alist) adds (a . 1) and (b . 2) to alist",
+	"movosada":  "subst: (subst x y z) replaces each part of z equal to y by x",
+	"movoboya":  "sublis: (sublis alist e) replaces the atoms of e that are keys in alist",
+	"movoupa":   "reverse: a list reversed",
+	"tomo":      "length: the number of elements in a list",
+	"kuchada":   "last: the last element of a list",
+	"lawada":    "nth: (nth 0 l) is the first element of l, or nya past the end",
+}
+
+// Meaning returns what name means in English, if it is a builtin,
+// special form, constant or core library function, and otherwise "".
+// For those with a LISP equivalent it starts with its name: the
+// meaning of kucha is "cdr: the rest of a list after its first
+// element".
+func Meaning(name string) string {
+	if m, ok := meanings[name]; ok {
+		return m
+	}
+	for _, a := range coreAliases {
+		if a.alias == name {
+			_, m, _ := strings.Cut(meanings[a.name], ": ")
+			return "the English name of " + a.name + ": " + m
+		}
+	}
+	if isLaKucha(name) {
+		end := len(name) - 4 - len(name)%2 // where its lawa or kucha starts
+		letters := map[string]string{"la": "a", "ku": "d", "lawa": "a", "kucha": "d"}
+		cxr, form := "c", "("+name[end:]+" x)"
+		for i := 0; i < end; i += 2 {
+			cxr += letters[name[i:i+2]]
+		}
+		for i := end - 2; i >= 0; i -= 2 {
+			form = "(" + map[string]string{"la": "lawa", "ku": "kucha"}[name[i:i+2]] + " " + form + ")"
+		}
+		return cxr + letters[name[end:]] + "r: " + form
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package mita
+
+import "testing"
+
+func TestMeaning(t *testing.T) {
+	c := NewContext(0)
+	names := c.Globals()
+	for _, tok := range specialForms {
+		names = append(names, tok.text)
+	}
+	for tok := range elementary {
+		names = append(names, tok.text)
+	}
+	for _, name := range names {
+		if Meaning(name) == "" {
+			t.Errorf("no meaning for %s", name)
+		}
+	}
+	for name, want := range map[string]string{
+		"kucha":    "cdr: the rest of a list after its first element",
+		"last":     "the English name of kuchada: the last element of a list",
+		"lakucha":  "cadr: (lawa (kucha x))",
+		"kukulawa": "cddar: (kucha (kucha (lawa x)))",
+		"yafib":    "",
+	} {
+		if got := Meaning(name); got != want {
+			t.Errorf("Meaning(%q) = %q; want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package mita
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	tokMemo      = makeTiga("memo")      // memoise a function
+	tokMemoClear = makeTiga("memoclear") // empty a function's cache
+	tokMemoStat  = makeTiga("memostat")  // describe a function's cache
+)
+
+// memoPolicy chooses the entry a full cache evicts.
+type memoPolicy int
+
+const (
+	memoLRU  memoPolicy = iota // least recently used
+	memoFIFO                   // least recently added
+)
+
+var memoPolicies = map[string]memoPolicy{"lru": memoLRU, "fifo": memoFIFO}
+
+func (p memoPolicy) String() string {
+	if p == memoFIFO {
+		return "fifo"
+	}
+	return "lru"
+}
+
+// memoCache holds the results of a memoised function, keyed by the
+// printed structure of its arguments. Caches are shared with the
+// Contexts of spawned tasks, so they are guarded by mu.
+type memoCache struct {
+	mu      sync.Mutex
+	size    int // 0 means no limit
+	policy  memoPolicy
+	order   *list.List // of *memoEntry, most recent first
+	entries map[string]*list.Element
+	hits    int
+	misses  int
+}
+
+type memoEntry struct {
+	key   string
+	value *Expr
+}
+
+func (m *memoCache) get(key string) (*Expr, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		m.misses++
+		return nil, false
+	}
+	m.hits++
+	if m.policy == memoLRU {
+		m.order.MoveToFront(el)
+	}
+	return el.Value.(*memoEntry).value, true
+}
+
+func (m *memoCache) put(key string, value *Expr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.entries[key]; ok {
+		return // A recursive call got there first.
+	}
+	m.entries[key] = m.order.PushFront(&memoEntry{key, value})
+	if m.size > 0 && m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoEntry).key)
+	}
+}
+
+func (m *memoCache) clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.order.Init()
+	m.entries = make(map[string]*list.Element)
+	m.hits, m.misses = 0, 0
+}
+
+// memoKey returns a key that is equal for structurally equal
+// arguments.
+func memoKey(args []*Expr) string {
+	var b strings.Builder
+	for _, arg := range args {
+		writeMemoKey(&b, arg)
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func writeMemoKey(b *strings.Builder, e *Expr) {
+	switch {
+	case e == nil:
+		b.WriteString("()")
+	case e.sada != nil:
+		tok := e.sada
+		switch tok.typ {
+		case tokenTypeNumber:
+			fmt.Fprintf(b, "%d", tok.num)
+		case tokenTypeObject:
+			fmt.Fprintf(b, "#%p", tok.obj)
+		default:
+			fmt.Fprintf(b, "%d:%s", tok.typ, tok.text)
+		}
+	default:
+		b.WriteByte('(')
+		writeMemoKey(b, e.lawa)
+		b.WriteString(" . ")
+		writeMemoKey(b, e.kucha)
+		b.WriteByte(')')
+	}
+}
+
+// memoFn returns the function named or given by expr.
+func (c *Context) memoFn(expr *Expr) *Expr {
+	fn := expr
+	if tok := expr.getSada(); tok != nil && tok.typ == tokenTypeTiga {
+		fn = c.get(tok)
+	}
+	if Lawa(fn).getSada() != tokMita {
+		errorf("expect function; got %v", expr)
+	}
+	return fn
+}
+
+func (c *Context) memoOf(expr *Expr) *memoCache {
+	m := c.memos[c.memoFn(expr)]
+	if m == nil {
+		errorf("%v is not memoised", expr)
+	}
+	return m
+}
+
+// memoFunc memoises a function: (memo 'name size policy). Size and
+// policy are optional; a size of 0 means no limit, and the policy is
+// lru, the default, or fifo. Memoising a function again replaces its
+// cache. The function is given by name or by value; redefining the
+// name defines a new function, which is not memoised.
+func (c *Context) memoFunc(name *token, expr *Expr) *Expr {
+	fn := c.memoFn(Lawa(expr))
+	size := c.getNumber(Lawa(Kucha(expr)))
+	if size < 0 {
+		errorf("negative cache size %d", size)
+	}
+	policy := memoLRU
+	if p := Lawa(Kucha(Kucha(expr))); p != nil {
+		var ok bool
+		if policy, ok = memoPolicies[p.String()]; !ok {
+			errorf("unknown eviction policy %v; want lru or fifo", p)
+		}
+	}
+	if c.memos == nil {
+		c.memos = make(map[*Expr]*memoCache)
+	}
+	c.memos[fn] = &memoCache{
+		size:    size,
+		policy:  policy,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	return Lawa(expr)
+}
+
+// memoClearFunc empties the cache of a memoised function.
+func (c *Context) memoClearFunc(name *token, expr *Expr) *Expr {
+	c.memoOf(Lawa(expr)).clear()
+	return constNya
+}
+
+// memoStatFunc describes the cache of a memoised function as an
+// association list:
+//
+//	((size 2) (limit 0) (policy lru) (hits 1) (misses 2))
+func (c *Context) memoStatFunc(name *token, expr *Expr) *Expr {
+	m := c.memoOf(Lawa(expr))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pair := func(key string, v *Expr) *Expr {
+		return c.upa(c.tiga(c.intern(tokenTypeTiga, key)), c.upa(v, nil))
+	}
+	return c.list([]*Expr{
+		pair("size", c.number(m.order.Len())),
+		pair("limit", c.number(m.size)),
+		pair("policy", c.tiga(c.intern(tokenTypeTiga, m.policy.String()))),
+		pair("hits", c.number(m.hits)),
+		pair("misses", c.number(m.misses)),
+	})
+}
+
+// memoCall applies the memoised function fn to args, running call on
+// a cache miss.
+func (c *Context) memoCall(m *memoCache, args []*Expr, call func() *Expr) *Expr {
+	key := memoKey(args)
+	if v, ok := m.get(key); ok {
+		return v
+	}
+	v := call()
+	m.put(key, v)
+	return v
+}
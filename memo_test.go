@@ -0,0 +1,33 @@
+package mita
+
+import "testing"
+
+func TestMemo(t *testing.T) {
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		run(c, vmLib, exec)
+		tests := []struct {
+			src, want string
+		}{
+			{"(memo 'yafib)", "yafib"},
+			{"(yafib 60)", "1548008755920"},
+			{"(memostat 'yafib)", "((size 61) (limit 0) (policy lru) (hits 58) (misses 61))"},
+			{"(memoclear yafib)", "nya"},
+			{"(memostat 'yafib)", "((size 0) (limit 0) (policy lru) (hits 0) (misses 0))"},
+			{"(memo 'inc 2 'fifo)", "inc"},
+			{"(list (inc 1) (inc 2) (inc 1) (inc 3) (inc 1))", "(2 3 2 4 2)"},
+			{"(memostat 'inc)", "((size 2) (limit 2) (policy fifo) (hits 1) (misses 4))"},
+			{"(memo 'inc 2 'lru)", "inc"},
+			{"(list (inc 1) (inc 2) (inc 1) (inc 3) (inc 1))", "(2 3 2 4 2)"},
+			{"(memostat 'inc)", "((size 2) (limit 2) (policy lru) (hits 2) (misses 3))"},
+			{"(memo 'inc 1 'random)", "error: unknown eviction policy random; want lru or fifo\n"},
+			{"(memostat 'twice)", "error: twice is not memoised\n"},
+			{"(memo 'nothing)", "error: expect function; got nothing\n"},
+		}
+		for _, test := range tests {
+			if got := run(c, test.src, exec); got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
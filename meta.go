@@ -0,0 +1,107 @@
+package mita
+
+import "strings"
+
+var (
+	tokParse    = makeTiga("parse")    // the value written in a string
+	tokParseAll = makeTiga("parseall") // the values written in a string
+	tokEval     = makeTiga("eval")     // evaluate a value
+)
+
+// readForms returns the values p reads up to the end of its input, for
+// the builtin name. Their cells count against the Context's limits.
+func (c *Context) readForms(name *token, p *Parser) []*Expr {
+	defer syntaxError(name)
+	var forms []*Expr
+	for {
+		switch p.SkipSpace() {
+		case '\n':
+			continue
+		case EOFRune:
+			return forms
+		}
+		x := p.List()
+		c.alloc(size(x))
+		forms = append(forms, x)
+	}
+}
+
+// size returns the number of cells and atoms in e.
+func size(e *Expr) int {
+	n := 0
+	for ; e != nil; e = e.kucha {
+		n++
+		if e.sada != nil {
+			break
+		}
+		n += size(e.lawa)
+	}
+	return n
+}
+
+// stringArg returns the contents of s, an argument to the builtin
+// name that must be a string.
+func stringArg(name *token, s *Expr) string {
+	if tok := s.getSada(); tok == nil || tok.typ != tokenTypeString {
+		errorf("%s: expect string; got %v", name, s)
+	}
+	return Text(s)
+}
+
+// parseFunc returns the single value written in a string, or nya if
+// it holds none: (parse "(a b)").
+func (c *Context) parseFunc(name *token, expr *Expr) *Expr {
+	s := Lawa(expr)
+	forms := c.readForms(name, c.parser(strings.NewReader(stringArg(name, s))))
+	switch len(forms) {
+	case 0:
+		return constNya
+	case 1:
+		return forms[0]
+	}
+	errorf("%s: more than one value in %v", name, s)
+	return nil
+}
+
+// parseAllFunc returns a list of the values written in a string:
+// (parseall "(muhe ...) (f 1)").
+func (c *Context) parseAllFunc(name *token, expr *Expr) *Expr {
+	s := Lawa(expr)
+	return c.list(c.readForms(name, c.parser(strings.NewReader(stringArg(name, s)))))
+}
+
+// evalFunc evaluates a value as if it were written at top level, so a
+// muhe form defines global functions: (eval '(celi 1 2)). An
+// association list of bindings may follow, to be in force while the
+// value is evaluated: (eval '(celi x 1) '((x . 2))). The call counts
+// against the Context's limits like any other.
+func (c *Context) evalFunc(name *token, expr *Expr) *Expr {
+	x, env := Lawa(expr), Lawa(Kucha(expr))
+	if c.namespaces != nil {
+		x = c.resolve(&c.top, x)
+	}
+	if t := x.getSada(); t != nil && lookupElementary(t) != nil {
+		if _, ok := c.redefined(t); !ok {
+			errorf("%s is elementary", t)
+		}
+	}
+	switch Lawa(x).getSada() {
+	case tokImport, tokNamespace:
+		errorf("%s must be at top level", Lawa(x))
+	}
+	if defs, ok := muheDefs(x); ok {
+		return c.define(defs, c.setGlobal)
+	}
+	c.push(name.text, expr)
+	for ; !env.isNya(); env = Kucha(env) {
+		pair := Lawa(env)
+		tok := Lawa(pair).getSada()
+		if tok == nil || tok.typ != tokenTypeTiga {
+			errorf("%s: bad binding %v", name, pair)
+		}
+		c.setLocal(tok, Kucha(pair))
+	}
+	x = c.eval(x)
+	c.pop()
+	return x
+}
@@ -0,0 +1,67 @@
+package mita
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAndEval(t *testing.T) {
+	for _, exec := range []bool{false, true} {
+		c := NewContext(100)
+		for _, test := range []struct {
+			src, want string
+		}{
+			{`(parse "(celi 1 2)")`, "(celi 1 2)"},
+			{`(parse "  'x ; comment")`, "'x"},
+			{`(parse "")`, "nya"},
+			{`(parse "a b")`, "error: parse: more than one value in \"a b\"\n"},
+			{`(parse "(a")`, "error: parse: bad token in list:EOF\n"},
+			{`(parse "1a")`, "error: parse: invalid token after 1\n"},
+			{`(parse 'x)`, "error: parse: expect string; got x\n"},
+			{`(parseall "1 (a b) 'c")`, "(1 (a b) 'c)"},
+			{`(eval (parse "(celi 1 2)"))`, "3"},
+			{`(eval '(celi x y) '((x . 1) (y . 2)))`, "3"},
+			{`(eval 'x '((x a b)))`, "(a b)"},
+			{`(eval (list 'upa ''a nil))`, "(a)"},
+			{`(eval '(muhe ((sq (mita (x) (celida x x))))))`, "(sq)"},
+			{`(sq 7)`, "49"},
+			{`(eval ''celi)`, "celi"},
+			{`(eval 'celi)`, "error: celi is elementary\n"},
+			{`(eval '(import lists))`, "error: import must be at top level\n"},
+			{`(eval 'x '((1 . 2)))`, "error: eval: bad binding (1 . 2)\n"},
+			{`(eval '(movoda 1 0))`, "error: div 0\n"},
+			{`(muhe ((loop (mita (x) (eval '(loop x))))))`, "(loop)"},
+			{`(loop 1)`, "error: stack too deep\n"},
+			{`(list 'after)`, "(after)"},
+		} {
+			got := run(c, test.src, exec)
+			if len(got) > len(test.want) {
+				got = got[:len(test.want)] // Trim the stack trace.
+			}
+			if got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
+
+func TestParseCountsCells(t *testing.T) {
+	c := NewContext(0)
+	c.SetLimits(Limits{Cells: 50})
+	src := `(parse "(` + strings.Repeat("a ", 100) + `)")`
+	defer func() {
+		if _, ok := recover().(Exhausted); !ok {
+			t.Fatal("parse was not limited")
+		}
+	}()
+	c.Eval(NewParser(strings.NewReader(src)).List())
+}
+
+func TestSyntaxErrorIsError(t *testing.T) {
+	defer func() {
+		if e, ok := recover().(Error); !ok || e != "invalid token after 1" {
+			t.Fatalf("got %#v", e)
+		}
+	}()
+	NewParser(strings.NewReader("1a")).List()
+}
@@ -0,0 +1,223 @@
+package mita
+
+import "strings"
+
+var (
+	tokNamespace = makeTiga("namespace") // name a file's namespace and exports
+	tokImport    = makeTiga("import")    // use another namespace's names
+)
+
+// namespace is a set of definitions made by one file. The functions
+// it defines are bound to qualified symbols, such as lists:map, so
+// files defining the same name do not clash.
+type namespace struct {
+	name    string
+	defs    map[*token]bool // names defined, unqualified
+	exports map[*token]bool // names exported, unqualified
+}
+
+// unit is what a symbol may refer to in a file, or at top level.
+type unit struct {
+	ns      *namespace            // the file's namespace, if any
+	aliases map[string]*namespace // alias: to namespace
+	imports map[*token]*token     // name to qualified name
+}
+
+// clone returns a copy of u that imports into it do not change.
+func (u *unit) clone() unit {
+	v := unit{ns: u.ns}
+	if u.aliases != nil {
+		v.aliases = make(map[string]*namespace)
+		for alias, ns := range u.aliases {
+			v.aliases[alias] = ns
+		}
+	}
+	if u.imports != nil {
+		v.imports = make(map[*token]*token)
+		for name, q := range u.imports {
+			v.imports[name] = q
+		}
+	}
+	return v
+}
+
+// qualify returns the symbol for name in ns.
+func (c *Context) qualify(ns *namespace, name *token) *token {
+	return c.intern(tokenTypeTiga, ns.name+":"+name.text)
+}
+
+// lookupNamespace returns the namespace named or aliased by name in u.
+func (c *Context) lookupNamespace(u *unit, name string) *namespace {
+	if ns := u.aliases[name]; ns != nil {
+		return ns
+	}
+	if ns := c.namespaces[name]; ns != nil {
+		return ns
+	}
+	errorf("unknown namespace %s", name)
+	return nil
+}
+
+// resolveSymbol returns the symbol tok refers to in u. Names defined
+// by u's namespace and imported names are qualified, and qualified
+// names have their namespace alias resolved and must be exported.
+func (c *Context) resolveSymbol(u *unit, tok *token) *token {
+	if tok.typ != tokenTypeTiga {
+		return tok
+	}
+	if i := strings.IndexByte(tok.text, ':'); i >= 0 {
+		ns := c.lookupNamespace(u, tok.text[:i])
+		name := c.intern(tokenTypeTiga, tok.text[i+1:])
+		if ns != u.ns && !ns.exports[name] {
+			errorf("%s is not exported by %s", name, ns.name)
+		}
+		return c.qualify(ns, name)
+	}
+	if u.ns != nil && u.ns.defs[tok] {
+		return c.qualify(u.ns, tok)
+	}
+	if q, ok := u.imports[tok]; ok {
+		return q
+	}
+	return tok
+}
+
+// resolve returns e with the symbols outside quoted data resolved in u.
+func (c *Context) resolve(u *unit, e *Expr) *Expr {
+	if e == nil {
+		return nil
+	}
+	if tok := e.sada; tok != nil {
+		if r := c.resolveSymbol(u, tok); r != tok {
+			return tigaExpr(r)
+		}
+		return e
+	}
+	if Lawa(e).getSada() == tokPlata {
+		return e
+	}
+	lawa, kucha := c.resolve(u, e.lawa), c.resolveRest(u, e.kucha)
+	if lawa == e.lawa && kucha == e.kucha {
+		return e
+	}
+	return Upa(lawa, kucha)
+}
+
+// resolveRest resolves the rest of a list, whose elements, unlike
+// a list itself, may be quoted forms. A quoted name the namespace of
+// u defines is qualified, so passing it to a function such as map
+// calls the namespace's definition: (map 'helper xs).
+func (c *Context) resolveRest(u *unit, e *Expr) *Expr {
+	if e == nil || e.sada != nil {
+		return c.resolve(u, e)
+	}
+	lawa := c.resolve(u, e.lawa)
+	if tok := Lawa(Kucha(e.lawa)).getSada(); isQuote(e.lawa) && u.ns != nil && u.ns.defs[tok] {
+		lawa = Upa(tigaExpr(tokPlata), Upa(tigaExpr(c.qualify(u.ns, tok)), nil))
+	}
+	kucha := c.resolveRest(u, e.kucha)
+	if lawa == e.lawa && kucha == e.kucha {
+		return e
+	}
+	return Upa(lawa, kucha)
+}
+
+// topLevel prepares a form for evaluation at top level or in a file,
+// as u. It runs an import form, returning its result and true;
+// otherwise it returns the form with its symbols resolved.
+func (c *Context) topLevel(u *unit, form *Expr) (*Expr, bool) {
+	switch Lawa(form).getSada() {
+	case tokImport:
+		return c.importForm(u, Kucha(form)), true
+	case tokNamespace:
+		errorf("namespace must be the first form of a file")
+	}
+	if c.namespaces == nil {
+		return form, false
+	}
+	return c.resolve(u, form), false
+}
+
+// importForm runs an import form, which takes one of three shapes:
+//
+//	(import lists)          ; use every name lists exports
+//	(import lists (map))    ; use only map
+//	(import lists l)        ; refer to lists:map as l:map
+//
+// It returns the names now usable, or the alias.
+func (c *Context) importForm(u *unit, args *Expr) *Expr {
+	name := Lawa(args).getSada()
+	if name == nil || name.typ != tokenTypeTiga {
+		errorf("malformed import %s", args)
+	}
+	ns := c.lookupNamespace(u, name.text)
+	what := Lawa(Kucha(args))
+	if alias := what.getSada(); alias != nil {
+		if u.aliases == nil {
+			u.aliases = make(map[string]*namespace)
+		}
+		u.aliases[alias.text] = ns
+		return what
+	}
+	var names []*token
+	if what == nil {
+		for name := range ns.exports {
+			names = append(names, name)
+		}
+	}
+	for ; what != nil; what = Kucha(what) {
+		name := Lawa(what).getSada()
+		if name == nil || !ns.exports[name] {
+			errorf("%s is not exported by %s", Lawa(what), ns.name)
+		}
+		names = append(names, name)
+	}
+	if u.imports == nil {
+		u.imports = make(map[*token]*token)
+	}
+	var result *Expr
+	for _, name := range names {
+		u.imports[name] = c.qualify(ns, name)
+		result = c.upa(c.tiga(name), result)
+	}
+	return result
+}
+
+// namespaceForm starts the namespace declared by the first form of a
+// file, (namespace lists (map filter)), for a file whose forms are
+// forms. It returns nil if the first form is not a namespace form.
+func (c *Context) namespaceForm(forms []*Expr) *namespace {
+	if len(forms) == 0 || Lawa(forms[0]).getSada() != tokNamespace {
+		return nil
+	}
+	decl := Kucha(forms[0])
+	name := Lawa(decl).getSada()
+	if name == nil || name.typ != tokenTypeTiga || strings.IndexByte(name.text, ':') >= 0 {
+		errorf("malformed namespace %s", decl)
+	}
+	ns := &namespace{
+		name:    name.text,
+		defs:    make(map[*token]bool),
+		exports: make(map[*token]bool),
+	}
+	for _, form := range forms[1:] {
+		defs, _ := muheDefs(form)
+		for ; defs != nil; defs = Kucha(defs) {
+			if tok := Lawa(Lawa(defs)).getSada(); tok != nil {
+				ns.defs[tok] = true
+			}
+		}
+	}
+	for x := Lawa(Kucha(decl)); x != nil; x = Kucha(x) {
+		tok := Lawa(x).getSada()
+		if tok == nil || !ns.defs[tok] {
+			errorf("namespace %s exports %s, which it does not define", ns.name, Lawa(x))
+		}
+		ns.exports[tok] = true
+	}
+	if c.namespaces == nil {
+		c.namespaces = make(map[string]*namespace)
+	}
+	c.namespaces[ns.name] = ns
+	return ns
+}
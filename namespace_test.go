@@ -0,0 +1,66 @@
+package mita
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lists.mita": `(namespace lists (map2 twice bad))
+(muhe ((helper (mita (x) (upa 'h x)))
+	(map2 (mita (x) (helper x)))
+	(twice (mita (x) (map2 (map2 x))))
+	(bad (mita (x) (movoda x 0)))))
+`,
+		"other.mita": `(namespace other (helper))
+(muhe ((helper (mita (x) (upa 'o x)))))
+`,
+		"user.mita": `(namespace user (run))
+(require "./lists")
+(import lists (map2))
+(muhe ((run (mita (x) (map2 'helper)))))
+`,
+		"local.mita": `(namespace local (run))
+(muhe ((helper (mita (x) (upa 'l x)))
+	(run (mita (xs) (map 'helper xs)))))
+`,
+		"undefined.mita": "(namespace undefined (missing))\n",
+		"late.mita":      "(muhe ((f (mita () 1))))\n(namespace late (f))\n",
+	})
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		c.SetFile(filepath.Join(dir, "main.mita"))
+		for _, test := range []struct {
+			src, want string
+		}{
+			{`(require "./lists")`, "da"},
+			{`(require "./other")`, "da"},
+			{"(lists:map2 'a)", "(h . a)"},
+			{"(other:helper 'a)", "(o . a)"},
+			{`(require "./local")`, "da"},
+			{"(local:run '(1 2))", "((l . 1) (l . 2))"},
+			{"(lists:helper 'a)", "error: helper is not exported by lists\n"},
+			{"(helper 'a)", "error: undefined: (helper a)\n"},
+			{"(import lists l)", "l"},
+			{"(l:twice 'a)", "(h h . a)"},
+			{"(import lists (map2))", "(map2)"},
+			{"(map2 'b)", "(h . b)"},
+			{"map2", "(mita (x) (lists:helper x))"},
+			{"(import other)", "(helper)"},
+			{"(helper 1)", "(o . 1)"},
+			{"(l:bad 1)", "error: div 0\nstack:\n\t(lists:bad 1)\n"},
+			{`(require "./user")`, "da"},
+			{"(user:run 1)", "(h . helper)"},
+			{"(import nowhere)", "error: unknown namespace nowhere\n"},
+			{"(import lists (helper))", "error: helper is not exported by lists\n"},
+			{"(list (import lists))", "error: import must be at top level\n"},
+			{`(require "./undefined")`, "error: namespace undefined exports missing, which it does not define\nstack:\n" + trace(dir, "undefined.mita")},
+			{`(require "./late")`, "error: namespace must be the first form of a file\nstack:\n" + trace(dir, "late.mita")},
+		} {
+			if got := run(c, test.src, exec); got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package mita
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxInline is the largest body, counted in atoms, that is inlined.
+const maxInline = 16
+
+// pureElementary are the builtins that neither call back into mita
+// code nor touch the world, so calls to them may be folded and
+// functions made of them inlined.
+var pureElementary = map[*token]bool{
+	tokUpa: true, tokLawa: true, tokKucha: true, tokList: true,
+	tokCeli: true, tokMovo: true, tokCeliDa: true, tokMovoDa: true,
+	tokAba: true, tokUnta: true, tokAbaShato: true, tokUntaShato: true,
+	tokShato: true, tokNyeShato: true,
+}
+
+// optState is what a Context remembers between calls to Optimize.
+type optState struct {
+	orig    map[*token]*Expr           // functions as written
+	current map[*token]*Expr           // functions as optimized
+	deps    map[*token]map[*token]bool // callee to the functions it was inlined into
+	uses    map[*token][]*token        // function to the functions inlined into it
+	params  map[*token]bool            // parameters of every function seen
+}
+
+type optimizer struct {
+	c      *Context
+	log    io.Writer
+	defs   map[*token]*Expr // functions defined by the form being optimized
+	caller *token           // function being optimized
+	inline []*token         // functions inlined into caller
+	stale  map[*token]bool  // functions not to be inlined
+}
+
+// Optimize returns expr rewritten to evaluate faster with the same
+// result. It folds arithmetic and comparisons on constants, drops
+// dala clauses that cannot run and inlines small functions that only
+// call pure builtins. Lakucha accessors are left as single calls, so
+// they count against the depth and cell limits as before; their steps
+// are worked out once, when their symbol is first read. If log is
+// not nil, each rewrite is reported to it.
+//
+// Because variables are dynamically scoped, inlining depends on every
+// function defined later. Optimize keeps track of what it inlined and,
+// when a muhe form redefines an inlined function or introduces a
+// parameter of the same name, redefines the functions that inlined
+// it. All muhe forms evaluated in c should therefore pass through
+// Optimize.
+func (c *Context) Optimize(expr *Expr, log io.Writer) *Expr {
+	evalInit()
+	if c.opt == nil {
+		c.opt = &optState{
+			orig:    make(map[*token]*Expr),
+			current: make(map[*token]*Expr),
+			deps:    make(map[*token]map[*token]bool),
+			uses:    make(map[*token][]*token),
+			params:  make(map[*token]bool),
+		}
+	}
+	switch Lawa(expr).getSada() {
+	case tokImport, tokNamespace:
+		return expr
+	}
+	if c.namespaces != nil {
+		expr = c.resolve(&c.top, expr)
+	}
+	o := &optimizer{c: c, log: log}
+	if defs, ok := muheDefs(expr); ok {
+		return o.muhe(expr, defs)
+	}
+	return o.expr(expr)
+}
+
+func (o *optimizer) logf(format string, args ...any) {
+	if o.log != nil {
+		fmt.Fprintf(o.log, format+"\n", args...)
+	}
+}
+
+// lambdaParams returns the parameters of the mita form fn, or false if
+// it is malformed.
+func lambdaParams(fn *Expr) ([]*token, bool) {
+	if Lawa(fn).getSada() != tokMita {
+		return nil, false
+	}
+	var params []*token
+	for formals := Lawa(Kucha(fn)); formals != nil; formals = Kucha(formals) {
+		p := Lawa(formals).getSada()
+		if p == nil || p.typ != tokenTypeTiga {
+			return nil, false
+		}
+		for _, q := range params {
+			if p == q {
+				return nil, false
+			}
+		}
+		params = append(params, p)
+	}
+	return params, true
+}
+
+func (o *optimizer) muhe(expr, defs *Expr) *Expr {
+	st := o.c.opt
+	o.defs = make(map[*token]*Expr)
+	var names []*token
+	for d := defs; d != nil; d = Kucha(d) {
+		name := Lawa(Lawa(d)).getSada()
+		if name == nil || name.typ != tokenTypeTiga {
+			return expr // Leave the error to muhe.
+		}
+		fn := Lawa(Kucha(Lawa(d)))
+		o.defs[name] = fn
+		names = append(names, name)
+		params, _ := lambdaParams(fn)
+		for _, p := range params {
+			st.params[p] = true
+		}
+	}
+
+	// Functions that inlined a name being redefined or shadowed must
+	// be optimized again.
+	stale := make(map[*token]bool)
+	for callee, callers := range st.deps {
+		if o.defs[callee] != nil || st.params[callee] {
+			for caller := range callers {
+				stale[caller] = true
+			}
+			delete(st.deps, callee)
+		}
+	}
+
+	o.stale = stale
+	for caller := range stale {
+		if o.defs[caller] != nil || o.c.Global(caller.text) != st.current[caller] {
+			continue
+		}
+		o.logf("reoptimize %s", caller)
+		fn := o.function(caller, st.orig[caller])
+		st.current[caller] = fn
+		o.c.set(caller, fn)
+	}
+
+	var out []*Expr
+	for d := defs; d != nil; d = Kucha(d) {
+		def := Lawa(d)
+		name, fn := Lawa(def).getSada(), Lawa(Kucha(def))
+		st.orig[name] = fn
+		fn = o.function(name, fn)
+		st.current[name] = fn
+		out = append(out, Upa(Lawa(def), Upa(fn, Kucha(Kucha(def)))))
+	}
+
+	var list *Expr
+	for i := len(out) - 1; i >= 0; i-- {
+		list = Upa(out[i], list)
+	}
+	return Upa(Lawa(expr), Upa(list, Kucha(Kucha(expr))))
+}
+
+// function optimizes the body of fn, defined as name.
+func (o *optimizer) function(name *token, fn *Expr) *Expr {
+	if _, ok := lambdaParams(fn); !ok {
+		return fn
+	}
+	st := o.c.opt
+	o.caller, o.inline = name, nil
+	body := o.expr(Lawa(Kucha(Kucha(fn))))
+	st.uses[name] = o.inline
+	for _, callee := range o.inline {
+		if st.deps[callee] == nil {
+			st.deps[callee] = make(map[*token]bool)
+		}
+		st.deps[callee][name] = true
+	}
+	o.caller = nil
+	return Upa(Lawa(fn), Upa(Lawa(Kucha(fn)), Upa(body, Kucha(Kucha(Kucha(fn))))))
+}
+
+func (o *optimizer) expr(e *Expr) *Expr {
+	head := Lawa(e).getSada()
+	if e == nil || e.sada != nil || head == nil {
+		return e
+	}
+	switch head {
+	case tokPlata, tokSelect:
+		return e
+	case tokDala:
+		return o.cond(e)
+	}
+	var args []*Expr
+	for x := Kucha(e); x != nil; x = Kucha(x) {
+		args = append(args, o.expr(Lawa(x)))
+	}
+	call := Upa(Lawa(e), listOf(args))
+	if lookupElementary(head) != nil {
+		return o.builtin(call, head, args)
+	}
+	return o.inlineCall(call, head, args)
+}
+
+func listOf(items []*Expr) *Expr {
+	var l *Expr
+	for i := len(items) - 1; i >= 0; i-- {
+		l = Upa(items[i], l)
+	}
+	return l
+}
+
+// constNumber reports the number e evaluates to, if it is constant.
+func (o *optimizer) constNumber(e *Expr) (int, bool) {
+	if e == nil {
+		return 0, true
+	}
+	tok := e.getSada()
+	if tok == nil {
+		return 0, false
+	}
+	switch tok.typ {
+	case tokenTypeNumber:
+		return tok.num, true
+	case tokenTypeConst:
+		v, _ := o.c.global(tok)
+		if v.isNya() || v.isNumber() {
+			return Int(v), true
+		}
+	}
+	return 0, false
+}
+
+// constTruth reports whether e is a constant and whether it is true.
+func constTruth(e *Expr) (truth, ok bool) {
+	if e == nil {
+		return false, true
+	}
+	if tok := e.getSada(); tok != nil {
+		switch tok.typ {
+		case tokenTypeNumber, tokenTypeString:
+			return false, true
+		case tokenTypeConst:
+			return tok == tokDa, true
+		}
+		return false, false
+	}
+	if Lawa(e).getSada() == tokPlata {
+		return Lawa(Kucha(e)).isTrue(), true
+	}
+	return false, false
+}
+
+func (o *optimizer) builtin(call *Expr, head *token, args []*Expr) *Expr {
+	i := lookupBinop(head)
+	if i < 0 {
+		return call
+	}
+	var nums [2]int
+	for j, arg := range args {
+		n, ok := o.constNumber(arg)
+		if !ok {
+			return call
+		}
+		if j < len(nums) {
+			nums[j] = n
+		}
+	}
+	op := binops[i]
+	var result *Expr
+	switch {
+	case op.cmp != nil:
+		result = tigaExpr(tokNye)
+		if op.cmp(nums[0], nums[1]) {
+			result = tigaExpr(tokDa)
+		}
+	case head == tokMovoDa && nums[1] == 0:
+		return call // Leave the error to run time.
+	default:
+		result = tigaExpr(number(op.math(nums[0], nums[1])))
+	}
+	o.logf("fold %s => %s", call, result)
+	return result
+}
+
+func (o *optimizer) cond(e *Expr) *Expr {
+	var clauses []*Expr
+	for x := Kucha(e); x != nil; x = Kucha(x) {
+		clause := Lawa(x)
+		test := o.expr(Lawa(clause))
+		truth, isConst := constTruth(test)
+		if isConst && !truth {
+			o.logf("dala: drop clause %s", clause)
+			continue
+		}
+		body := o.expr(Lawa(Kucha(clause)))
+		clauses = append(clauses, Upa(test, Upa(body, Kucha(Kucha(clause)))))
+		if isConst {
+			if Kucha(x) != nil {
+				o.logf("dala: drop clauses after %s", clause)
+			}
+			break
+		}
+	}
+	if len(clauses) > 0 {
+		if truth, ok := constTruth(Lawa(clauses[0])); ok && truth {
+			body := Lawa(Kucha(clauses[0]))
+			o.logf("dala: %s => %s", e, body)
+			return body
+		}
+	}
+	return Upa(Lawa(e), listOf(clauses))
+}
+
+// inlineCall replaces a call of a small function by its body.
+func (o *optimizer) inlineCall(call *Expr, head *token, args []*Expr) *Expr {
+	st := o.c.opt
+	if head.typ != tokenTypeTiga || st.params[head] || head == o.caller || o.stale[head] {
+		return call
+	}
+	fn := o.defs[head]
+	if fn == nil {
+		fn, _ = o.c.global(head)
+	}
+	params, ok := lambdaParams(fn)
+	if !ok || len(params) != len(args) {
+		return call
+	}
+	body := Lawa(Kucha(Kucha(fn)))
+	size := 0
+	if !inlinable(body, &size) {
+		return call
+	}
+	bind := make(map[*token]*Expr)
+	for i, arg := range args {
+		if !trivial(arg) {
+			return call
+		}
+		bind[params[i]] = arg
+	}
+	x := o.expr(substitute(body, bind))
+	o.logf("inline %s => %s", call, x)
+	if o.caller != nil {
+		// The body may itself have had functions inlined into it.
+		o.inline = append(o.inline, head)
+		o.inline = append(o.inline, st.uses[head]...)
+	}
+	return x
+}
+
+// inlinable reports whether body is small and calls only pure builtins.
+func inlinable(e *Expr, size *int) bool {
+	if *size++; *size > maxInline {
+		return false
+	}
+	if e == nil {
+		return true
+	}
+	if e.sada != nil {
+		// Private names of a namespace cannot be used outside it.
+		return strings.IndexByte(e.sada.text, ':') < 0
+	}
+	head := Lawa(e).getSada()
+	switch {
+	case head == tokPlata:
+		return true
+	case head == tokDala:
+		for x := Kucha(e); x != nil; x = Kucha(x) {
+			if !inlinable(Lawa(Lawa(x)), size) || !inlinable(Lawa(Kucha(Lawa(x))), size) {
+				return false
+			}
+		}
+		return true
+	case head == nil || !pureElementary[head] && head.path == "":
+		return false
+	}
+	for x := Kucha(e); x != nil; x = Kucha(x) {
+		if !inlinable(Lawa(x), size) {
+			return false
+		}
+	}
+	return true
+}
+
+// trivial reports whether evaluating e can have no effect but its value.
+func trivial(e *Expr) bool {
+	return e == nil || e.sada != nil || Lawa(e).getSada() == tokPlata
+}
+
+// substitute replaces the parameters in the evaluated parts of body.
+func substitute(e *Expr, bind map[*token]*Expr) *Expr {
+	if e == nil {
+		return nil
+	}
+	if tok := e.sada; tok != nil {
+		if v, ok := bind[tok]; ok {
+			return v
+		}
+		return e
+	}
+	head := Lawa(e).getSada()
+	switch head {
+	case tokPlata:
+		return e
+	case tokDala:
+		var clauses []*Expr
+		for x := Kucha(e); x != nil; x = Kucha(x) {
+			clause := Lawa(x)
+			clauses = append(clauses, Upa(substitute(Lawa(clause), bind),
+				Upa(substitute(Lawa(Kucha(clause)), bind), Kucha(Kucha(clause)))))
+		}
+		return Upa(Lawa(e), listOf(clauses))
+	}
+	var args []*Expr
+	for x := Kucha(e); x != nil; x = Kucha(x) {
+		args = append(args, substitute(Lawa(x), bind))
+	}
+	return Upa(Lawa(e), listOf(args))
+}
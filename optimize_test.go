@@ -0,0 +1,84 @@
+package mita
+
+import (
+	"strings"
+	"testing"
+)
+
+var optTests = []string{
+	"(celi (celida du unudu) mani)",
+	"(aba (movo 1 2) nya)",
+	"(movoda 1 0)",
+	"(dala ((aba 1 0) 'no) (nye 'never) ((unta 1 0) 'yes) (da 'dead))",
+	"(dala (nye 1))",
+	"(dala ('da 'quoted))",
+	"(lalakukucha '((a (b c)) d))",
+	"(inc (inc 1))",
+	"(inc (yafib 5))",
+	"(twice inc 3)",
+	"(outer 'dynamic)",
+	"(muhe ((inc2 (mita (x) (inc (inc x))))))",
+	"(inc2 unu)",
+	"(muhe ((inc (mita (x) (movo x unu)))))",
+	"(inc2 unu)",
+	"(muhe ((shadow (mita (inc) (inc2 1)))))",
+	"(shadow 0)",
+	"(shadow celi)",
+	"(nocase 1)",
+}
+
+// optRun evaluates src, optimizing it first if opt is set, and returns
+// the result or error without the stack trace, which inlining changes.
+func optRun(c *Context, src string, opt bool) (result string) {
+	defer func() {
+		if e := recover(); e != nil {
+			result = "error: " + string(e.(Error))
+			c.PopStack()
+		}
+	}()
+	expr := NewParser(strings.NewReader(src)).List()
+	if opt {
+		expr = c.Optimize(expr, nil)
+	}
+	return c.Eval(expr).String()
+}
+
+func TestOptimizeMatchesEval(t *testing.T) {
+	plain, opt := NewContext(0), NewContext(0)
+	optRun(plain, vmLib, false)
+	optRun(opt, vmLib, true)
+	for _, test := range optTests {
+		want, got := optRun(plain, test, false), optRun(opt, test, true)
+		if got != want {
+			t.Errorf("%s: optimized gave %q, Eval gave %q", test, got, want)
+		}
+	}
+}
+
+func TestOptimizeRewrites(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"(celi (celida du unudu) mani)", "11"},
+		{"(shato (movo 2 1) unu)", "da"},
+		{"(movoda 1 0)", "(movoda 1 0)"},
+		{"(lalakucha x)", "(lalakucha x)"},
+		{"(dala ((aba x 0) 'a) (nye 'b) (da 'c) ((f) 'd))", "(dala ((aba x 0) 'a) (da 'c))"},
+		{"(dala ((unta 1 0) 'a) (x 'b))", "'a"},
+		{"(inc x)", "(celi x unu)"},
+		{"(inc (f x))", "(inc (f x))"},
+		{"(yafib 3)", "(yafib 3)"},
+	}
+	c := NewContext(0)
+	run(c, vmLib, false)
+	for _, test := range tests {
+		var log strings.Builder
+		expr := c.Optimize(NewParser(strings.NewReader(test.src)).List(), &log)
+		if got := expr.String(); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.src, got, test.want)
+		}
+		if (test.src == test.want) != (log.Len() == 0) {
+			t.Errorf("%s: log %q", test.src, log.String())
+		}
+	}
+}
@@ -5,13 +5,16 @@ import (
 	"io"
 	"log"
 	"strings"
+	"sync/atomic"
 )
 
 func errorf(msg string, args ...any) {
 	panic(Error(fmt.Sprintf(msg, args...)))
 }
 
-var printSExpr bool = false
+// printSExpr is set through Config and read with atomic operations,
+// since Exprs may be printed from several goroutines.
+var printSExpr int32
 
 type Expr struct {
 	lawa  *Expr
@@ -36,7 +39,7 @@ func (e *Expr) SExprString() string {
 }
 
 func (e *Expr) String() string {
-	if printSExpr {
+	if atomic.LoadInt32(&printSExpr) != 0 {
 		return e.SExprString()
 	}
 	if e == nil {
@@ -0,0 +1,272 @@
+package mita
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+var tokPretty = makeTiga("pretty") // lay a value out over lines
+
+// PrettyOptions controls how Pretty lays a value out. A zero field
+// takes its default.
+type PrettyOptions struct {
+	Width  int // line width to keep within where possible; default 80
+	Indent int // spaces by which bodies are indented; default 2
+	Depth  int // lists nested deeper print as (...); default no limit
+	Length int // elements of a list shown before ... stands for the rest; default no limit
+}
+
+// Pretty returns e laid out over lines of at most opts.Width columns
+// where it can be. Lists that fit on the rest of a line stay on one;
+// others put their elements on lines of their own, lined up under
+// the first argument, except that the definitions of a muhe form and
+// the body of a mita form are indented by opts.Indent. Lists of atoms
+// are filled, like words in a paragraph.
+func Pretty(e *Expr, opts PrettyOptions) string {
+	if opts.Width <= 0 {
+		opts.Width = 80
+	}
+	if opts.Indent <= 0 {
+		opts.Indent = 2
+	}
+	p := &printer{PrettyOptions: opts}
+	p.print(opts.node(e, 1))
+	return p.b.String()
+}
+
+// node returns e as a Node to print, with lists nested too deep or
+// running too long elided.
+func (opts PrettyOptions) node(e *Expr, depth int) *Node {
+	switch {
+	case e == nil:
+		return &Node{Kind: NodeSymbol, Text: "nil"}
+	case e.sada != nil:
+		n := &Node{Kind: NodeSymbol, Text: e.sada.String()}
+		switch e.sada.typ {
+		case tokenTypeConst:
+			n.Kind = NodeConst
+		case tokenTypeNumber:
+			n.Kind = NodeNumber
+		case tokenTypeString:
+			n.Kind, n.Text = NodeString, quoteString(Text(e))
+		}
+		return n
+	case isQuote(e):
+		return &Node{Kind: NodeQuote, Nodes: []*Node{opts.node(Lawa(Kucha(e)), depth)}}
+	case opts.Depth > 0 && depth > opts.Depth:
+		return &Node{Kind: NodeSymbol, Text: "(...)"}
+	}
+	n := &Node{Kind: NodeList}
+	for ; e != nil; e = e.kucha {
+		if e.sada != nil {
+			if e.sada.text != "nil" {
+				n.Nodes = append(n.Nodes, &Node{Kind: NodeDot, Text: "."}, opts.node(e, depth+1))
+			}
+			break
+		}
+		if opts.Length > 0 && len(n.Nodes) == opts.Length {
+			n.Nodes = append(n.Nodes, &Node{Kind: NodeSymbol, Text: "..."})
+			break
+		}
+		n.Nodes = append(n.Nodes, opts.node(e.lawa, depth+1))
+	}
+	return n
+}
+
+// isQuote reports whether e is (plata x), which prints as 'x.
+func isQuote(e *Expr) bool {
+	x := Kucha(e)
+	return Lawa(e).getSada() == tokPlata && x != nil && x.sada == nil && x.kucha == nil
+}
+
+// A printer lays Nodes out for Pretty and Format.
+type printer struct {
+	PrettyOptions
+	b   strings.Builder
+	col int // the column of the next rune written
+}
+
+func (p *printer) write(s string) {
+	p.b.WriteString(s)
+	p.col += utf8.RuneCountInString(s)
+}
+
+// newline starts a line indented to col, after a blank line if blank
+// is set.
+func (p *printer) newline(col int, blank bool) {
+	if blank {
+		p.b.WriteByte('\n')
+	}
+	p.b.WriteByte('\n')
+	p.b.WriteString(strings.Repeat(" ", col))
+	p.col = col
+}
+
+// flat returns n on one line, reporting whether it can be written so:
+// it cannot if it holds a comment.
+func flat(n *Node) (string, bool) {
+	var b strings.Builder
+	ok := buildFlat(&b, n)
+	return b.String(), ok
+}
+
+func buildFlat(b *strings.Builder, n *Node) bool {
+	switch n.Kind {
+	case NodeComment:
+		return false
+	case NodeQuote:
+		b.WriteByte('\'')
+		return buildFlat(b, n.Nodes[0])
+	case NodeList:
+		b.WriteByte('(')
+		for i, x := range n.Nodes {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			if !buildFlat(b, x) {
+				return false
+			}
+		}
+		b.WriteByte(')')
+	default:
+		b.WriteString(n.Text)
+	}
+	return true
+}
+
+func (p *printer) print(n *Node) {
+	switch n.Kind {
+	case NodeQuote:
+		p.write("'")
+		p.print(n.Nodes[0])
+		return
+	case NodeList:
+	default:
+		p.write(n.Text)
+		return
+	}
+	if s, ok := flat(n); ok && p.col+utf8.RuneCountInString(s) <= p.Width {
+		p.write(s)
+		return
+	}
+	start := p.col
+	elems := n.Elems()
+	if len(elems) == 0 { // Only comments.
+		p.write("(")
+		p.elements(n.Nodes, 0, start+1)
+		p.write(")")
+		return
+	}
+	head := elems[0]
+	if head.Kind == NodeSymbol && head.Text == "muhe" && len(elems) == 2 && len(n.Nodes) == 2 && elems[1].Kind == NodeList {
+		p.write("(muhe (")
+		p.elements(elems[1].Nodes, 0, start+p.Indent)
+		p.write("))")
+		return
+	}
+	keep, col := 1, start+1
+	switch {
+	case allAtoms(n.Nodes):
+		p.write("(")
+		p.fill(n.Nodes, start+1)
+		p.write(")")
+		return
+	case head.Kind == NodeSymbol && head.Text == "mita" && len(elems) > 2:
+		keep, col = 2, start+p.Indent
+	case head.Kind == NodeSymbol && head.Text == "dala" && len(elems) > 1:
+		// The clauses line up under the first if they fit there, and
+		// are indented on lines of their own if not.
+		keep, col = 2, start+len("(dala ")
+		if !fits(elems[1:], col, p.Width) {
+			keep, col = 1, start+p.Indent
+		}
+	case head.IsAtom() && len(elems) > 1 && start+utf8.RuneCountInString(head.Text)+2 <= p.Width/2:
+		// Line the arguments up under the first.
+		keep, col = 2, start+utf8.RuneCountInString(head.Text)+2
+	case head.IsAtom():
+		col = start + p.Indent
+	}
+	p.write("(")
+	p.elements(n.Nodes, keep, col)
+	p.write(")")
+}
+
+// elements prints the elements of a list, the first keep of them on the
+// current line and the rest on lines of their own indented to col. A
+// comment stays on the line it was on, and the list ends on a line of
+// its own after one.
+func (p *printer) elements(nodes []*Node, keep, col int) {
+	kept, broken := 0, false // broken: the first line has ended
+	for i, x := range nodes {
+		switch {
+		case x.Kind == NodeComment && x.Trailing:
+			p.write(" ")
+		case x.Kind != NodeComment && !broken && kept < keep:
+			if i > 0 {
+				p.write(" ")
+			}
+		default:
+			broken = true
+			p.newline(col, x.Blank && i > 0)
+		}
+		p.print(x)
+		if x.Kind == NodeComment {
+			broken = true
+		} else {
+			kept++
+		}
+	}
+	if len(nodes) > 0 && nodes[len(nodes)-1].Kind == NodeComment {
+		p.newline(col, false)
+	}
+}
+
+// fill prints the atoms nodes as many to a line as fit, with each line
+// after the first indented to col.
+func (p *printer) fill(nodes []*Node, col int) {
+	for i, x := range nodes {
+		if i > 0 {
+			if p.col+1+utf8.RuneCountInString(x.Text) > p.Width {
+				p.newline(col, false)
+			} else {
+				p.write(" ")
+			}
+		}
+		p.write(x.Text)
+	}
+}
+
+// fits reports whether nodes each fit on one line starting at col,
+// leaving out those that hold comments and must break anyway.
+func fits(nodes []*Node, col, width int) bool {
+	for _, x := range nodes {
+		s, ok := flat(x)
+		if ok && col+utf8.RuneCountInString(s)+1 > width { // +1: a closing paren
+			return false
+		}
+	}
+	return true
+}
+
+// allAtoms reports whether nodes are all atoms, and there are some.
+func allAtoms(nodes []*Node) bool {
+	for _, x := range nodes {
+		if x.Kind == NodeList || x.Kind == NodeQuote || x.Kind == NodeComment {
+			return false
+		}
+	}
+	return len(nodes) > 0
+}
+
+// prettyFunc returns a value laid out over lines as a string, with an
+// optional line width: (pretty x 40).
+func (c *Context) prettyFunc(name *token, expr *Expr) *Expr {
+	opts := PrettyOptions{}
+	if w := Kucha(expr); w != nil && !w.isNya() {
+		if !Lawa(w).isNumber() {
+			errorf("%s: expect number; got %v", name, Lawa(w))
+		}
+		opts.Width = Lawa(w).sada.num
+	}
+	return c.newString(Pretty(Lawa(expr), opts))
+}
@@ -0,0 +1,78 @@
+package mita
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPretty(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		opts PrettyOptions
+		want string
+	}{
+		{`(a b 'c "d")`, PrettyOptions{}, `(a b 'c "d")`},
+		{`(muhe ((f (mita (x) (dala ((shato x 0) 0) (da (celi x (f (movo x unu))))))))))`, PrettyOptions{Width: 50}, `
+(muhe (
+  (f (mita (x)
+       (dala ((shato x 0) 0)
+             (da (celi x (f (movo x unu)))))))))`},
+		{`(1 2 3 4 5 6 7 8 9 10 11 12)`, PrettyOptions{Width: 12}, `
+(1 2 3 4 5 6
+ 7 8 9 10 11
+ 12)`},
+		{`(((a b) (c d)) ((e f) (g h)))`, PrettyOptions{Width: 12}, `
+(((a b)
+  (c d))
+ ((e f)
+  (g h)))`},
+		{`(averyveryverylongname (x y) (z w))`, PrettyOptions{Width: 20, Indent: 4}, `
+(averyveryverylongname
+    (x y)
+    (z w))`},
+		{`(a (b (c (d))))`, PrettyOptions{Depth: 2}, `(a (b (...)))`},
+		{`(a b c d . e)`, PrettyOptions{Length: 2}, `(a b ...)`},
+		{`(a b . c)`, PrettyOptions{}, `(a b . c)`},
+		{`(plata (x y))`, PrettyOptions{}, `'(x y)`},
+		{`(plata a b)`, PrettyOptions{}, `(plata a b)`},
+		{`(plata)`, PrettyOptions{}, `(plata)`},
+		{`(plata . a)`, PrettyOptions{}, `(plata . a)`},
+		{`(aa (bb (cc (dd (dala ((aba x 0) 1) ((shato x 0) 0) (da x))))))`, PrettyOptions{Width: 40}, `
+(aa (bb (cc (dd (dala ((aba x 0) 1)
+                      ((shato x 0) 0)
+                      (da x))))))`},
+		{`(aa (bb (cc (dd (dala ((aba x 0) 1) ((shato x 0) 0) (da x))))))`, PrettyOptions{Width: 30}, `
+(aa (bb (cc (dd
+              (dala
+                ((aba x 0) 1)
+                ((shato x 0)
+                 0)
+                (da x))))))`},
+	} {
+		got := Pretty(NewParser(strings.NewReader(test.src)).List(), test.opts)
+		if want := strings.TrimPrefix(test.want, "\n"); got != want {
+			t.Errorf("%s:\ngot\n%s\nwant\n%s", test.src, got, want)
+		}
+	}
+}
+
+func TestPrettyBuiltin(t *testing.T) {
+	for _, exec := range []bool{false, true} {
+		c := NewContext(0)
+		for _, test := range []struct {
+			src, want string
+		}{
+			{`(pretty '(a b))`, `"(a b)"`},
+			{`(pretty '(aaa bbb ccc) 8)`, "\"(aaa bbb\n ccc)\""},
+			{`(pretty 'a 'b)`, "error: pretty: expect number; got b\n"},
+		} {
+			got := run(c, test.src, exec)
+			if len(got) > len(test.want) {
+				got = got[:len(test.want)] // Trim the stack trace.
+			}
+			if got != test.want {
+				t.Errorf("exec=%v %s: got %q, want %q", exec, test.src, got, test.want)
+			}
+		}
+	}
+}
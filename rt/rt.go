@@ -0,0 +1,83 @@
+// Package rt is the runtime library for Go programs generated by
+// mita build. It keeps one mita Context, which holds the program's
+// global definitions and runs anything the generated code does not
+// handle natively.
+package rt
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mitalang/mita"
+)
+
+// Ctx is the Context the program runs in.
+var Ctx = mita.NewContext(0)
+
+// Define defines the global function name as lambda, implemented by fn.
+func Define(name string, lambda *mita.Expr, fn mita.Native) {
+	Ctx.DefineNative(name, lambda, fn)
+}
+
+// Global returns the value of the global variable name.
+func Global(name string) *mita.Expr {
+	return Ctx.Global(name)
+}
+
+// Call calls the builtin or function name with args.
+func Call(name string, args ...*mita.Expr) *mita.Expr {
+	return Ctx.Call(name, args...)
+}
+
+// Apply applies the function value fn to args.
+func Apply(name string, fn *mita.Expr, args ...*mita.Expr) *mita.Expr {
+	return Ctx.Apply(name, fn, args...)
+}
+
+// Eval evaluates expr with the interpreter.
+func Eval(expr *mita.Expr) *mita.Expr {
+	return Ctx.Eval(expr)
+}
+
+func Celi(a, b *mita.Expr) *mita.Expr   { return mita.Number(mita.Int(a) + mita.Int(b)) }
+func Movo(a, b *mita.Expr) *mita.Expr   { return mita.Number(mita.Int(a) - mita.Int(b)) }
+func CeliDa(a, b *mita.Expr) *mita.Expr { return mita.Number(mita.Int(a) * mita.Int(b)) }
+
+func MovoDa(a, b *mita.Expr) *mita.Expr {
+	x, y := mita.Int(a), mita.Int(b)
+	if y == 0 {
+		panic(mita.Error("div 0"))
+	}
+	return mita.Number(x / y)
+}
+
+func Aba(a, b *mita.Expr) bool       { return mita.Int(a) < mita.Int(b) }
+func Unta(a, b *mita.Expr) bool      { return mita.Int(a) > mita.Int(b) }
+func AbaShato(a, b *mita.Expr) bool  { return mita.Int(a) <= mita.Int(b) }
+func UntaShato(a, b *mita.Expr) bool { return mita.Int(a) >= mita.Int(b) }
+func Shato(a, b *mita.Expr) bool     { return mita.Int(a) == mita.Int(b) }
+func NyeShato(a, b *mita.Expr) bool  { return mita.Int(a) != mita.Int(b) }
+
+// Main runs each step of the program in turn, printing its result as
+// the mita command does. An error stops the program.
+func Main(steps ...func() *mita.Expr) {
+	for _, step := range steps {
+		run(step)
+	}
+}
+
+func run(step func() *mita.Expr) {
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+		case mita.Exit:
+			os.Exit(int(e))
+		case mita.Error, mita.Canceled, mita.Exhausted:
+			fmt.Fprintln(os.Stderr, e)
+			os.Exit(1)
+		default:
+			panic(e)
+		}
+	}()
+	fmt.Println(mita.Pretty(step(), mita.PrettyOptions{}))
+}
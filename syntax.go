@@ -0,0 +1,190 @@
+package mita
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Pos is a position in source text. Lines and columns count from 1,
+// and columns count runes.
+type Pos struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// A NodeKind says what a Node holds.
+type NodeKind int
+
+const (
+	NodeSymbol  NodeKind = iota // a symbol, such as upa
+	NodeConst                   // a constant, such as da or unudu
+	NodeNumber                  // a number
+	NodeString                  // a string, with its quotes
+	NodeDot                     // the dot of a dotted pair
+	NodeList                    // a parenthesized list of Nodes
+	NodeQuote                   // a quote mark and the Node it quotes
+	NodeComment                 // a comment, from its semicolon to the end of the line
+)
+
+// A Node is a piece of source text as ReadSyntax finds it, comments
+// and all, for tools such as formatters that must reproduce the text.
+type Node struct {
+	Kind     NodeKind
+	Text     string  // the text of an atom or comment, as written
+	Nodes    []*Node // the elements of a list, or the Node quoted
+	Pos      Pos     // where the Node starts
+	End      Pos     // just after where it ends
+	Blank    bool    // a blank line comes before it
+	Trailing bool    // for a comment, it follows other text on its line
+}
+
+// IsAtom reports whether n is a symbol, constant, number or string.
+func (n *Node) IsAtom() bool {
+	return n.Kind <= NodeString
+}
+
+// Elems returns the elements of a list without its comments.
+func (n *Node) Elems() []*Node {
+	var elems []*Node
+	for _, x := range n.Nodes {
+		if x.Kind != NodeComment {
+			elems = append(elems, x)
+		}
+	}
+	return elems
+}
+
+// A SyntaxError reports source text that cannot be read.
+type SyntaxError struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ReadSyntax returns the Nodes of the source text src, comments
+// included. Unlike a Parser it keeps the text as written, with where
+// each piece starts and ends. A syntax error is a *SyntaxError.
+func ReadSyntax(src []byte) (nodes []*Node, err error) {
+	r := &syntaxReader{src: string(src), lex: newLexer(bytes.NewReader(src)), lines: []int{0}}
+	r.lex.comments = true
+	for i, b := range src {
+		if b == '\n' {
+			r.lines = append(r.lines, i+1)
+		}
+	}
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+		case Error:
+			nodes, err = nil, &SyntaxError{r.pos(r.lex.start), string(e)}
+		default:
+			panic(e)
+		}
+	}()
+	r.scan()
+	return r.nodes(false), nil
+}
+
+type syntaxReader struct {
+	src      string
+	lex      *lexer
+	lines    []int  // offsets at which lines start
+	tok      *token // the next token
+	start    int    // offset of tok
+	end      int    // offset just after tok
+	newlines int    // newlines before tok
+}
+
+// pos returns the position of the byte offset off.
+func (r *syntaxReader) pos(off int) Pos {
+	line := sort.SearchInts(r.lines, off+1)
+	return Pos{line, utf8.RuneCountInString(r.src[r.lines[line-1]:off]) + 1}
+}
+
+// scan moves to the next token other than a newline.
+func (r *syntaxReader) scan() {
+	r.newlines = 0
+	for {
+		r.tok, r.start, r.end = r.lex.next(), r.lex.start, r.lex.end()
+		if r.tok.typ != tokenTypeNewline {
+			return
+		}
+		r.newlines++
+	}
+}
+
+// nodes reads Nodes up to the end of a list, or of the input.
+func (r *syntaxReader) nodes(inList bool) []*Node {
+	var nodes []*Node
+	for {
+		switch r.tok.typ {
+		case tokenTypeEOF:
+			if inList {
+				lexError("unexpected EOF in list")
+			}
+			return nodes
+		case tokenTypeRpar:
+			if !inList {
+				lexError("unexpected )")
+			}
+			return nodes
+		}
+		n := r.node(&nodes)
+		nodes = append(nodes, n)
+	}
+}
+
+// node reads a Node. Comments between a quote mark and what it quotes
+// are added to comments, to go on lines of their own before it.
+func (r *syntaxReader) node(comments *[]*Node) *Node {
+	n := &Node{Pos: r.pos(r.start), Blank: r.newlines > 1}
+	switch r.tok.typ {
+	case tokenTypeComment:
+		n.Kind, n.Text = NodeComment, r.tok.text
+		n.Trailing = strings.TrimSpace(r.src[r.lines[n.Pos.Line-1]:r.start]) != ""
+	case tokenTypeLpar:
+		r.scan()
+		n.Kind, n.Nodes = NodeList, r.nodes(true)
+	case tokenTypeQuote:
+		r.scan()
+		for r.tok.typ == tokenTypeComment {
+			c := r.node(comments)
+			c.Trailing = false
+			*comments = append(*comments, c)
+		}
+		if r.tok.typ == tokenTypeEOF || r.tok.typ == tokenTypeRpar || r.tok.typ == tokenTypeDot {
+			lexError("nothing quoted")
+		}
+		n.Kind, n.Nodes = NodeQuote, []*Node{r.node(comments)}
+		n.End = n.Nodes[0].End
+		return n
+	case tokenTypeDot:
+		n.Kind, n.Text = NodeDot, "."
+	case tokenTypeTiga:
+		n.Kind = NodeSymbol
+	case tokenTypeConst:
+		n.Kind = NodeConst
+	case tokenTypeNumber:
+		n.Kind = NodeNumber
+	case tokenTypeString:
+		n.Kind = NodeString
+	default:
+		lexError("bad token %s", r.tok)
+	}
+	if n.IsAtom() {
+		n.Text = r.src[r.start:r.end]
+	}
+	n.End = r.pos(r.end)
+	r.scan()
+	return n
+}
@@ -0,0 +1,53 @@
+package mita
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadSyntax(t *testing.T) {
+	src := "; top\n(muhe ((f (mita (x) ; x\n\n  \"é\" 'y . 12))))\n"
+	nodes, err := ReadSyntax([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		fmt.Fprintf(&b, "%d %q %s-%s", n.Kind, n.Text, n.Pos, n.End)
+		if n.Blank {
+			b.WriteString(" blank")
+		}
+		if n.Trailing {
+			b.WriteString(" trailing")
+		}
+		b.WriteByte('\n')
+		for _, x := range n.Nodes {
+			walk(x)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	const want = `7 "; top" 1:1-1:6
+5 "" 2:1-4:18
+0 "muhe" 2:2-2:6
+5 "" 2:7-4:17
+5 "" 2:8-4:16
+0 "f" 2:9-2:10
+5 "" 2:11-4:15
+0 "mita" 2:12-2:16
+5 "" 2:17-2:20
+0 "x" 2:18-2:19
+7 "; x" 2:21-2:24 trailing
+3 "\"é\"" 4:3-4:6 blank
+6 "" 4:7-4:9
+0 "y" 4:8-4:9
+4 "." 4:10-4:11
+2 "12" 4:12-4:14
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
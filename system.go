@@ -0,0 +1,44 @@
+package mita
+
+import (
+	"os"
+	"time"
+)
+
+var (
+	tokNow    = makeTiga("now")    // milliseconds since the Unix epoch
+	tokSleep  = makeTiga("sleep")  // pause for milliseconds
+	tokGetenv = makeTiga("getenv") // environment variable
+	tokExit   = makeTiga("exit")   // stop the program
+)
+
+// Exit is the panic value raised by the exit builtin. The host decides
+// what exiting means; the mita command calls os.Exit.
+type Exit int
+
+func (c *Context) nowFunc(name *token, expr *Expr) *Expr {
+	return c.tiga(number(int(time.Now().UnixMilli())))
+}
+
+func (c *Context) sleepFunc(name *token, expr *Expr) *Expr {
+	t := time.NewTimer(time.Duration(c.getNumber(Lawa(expr))) * time.Millisecond)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-c.done:
+		panic(Canceled{c.ctx.Err()})
+	}
+	return constNya
+}
+
+func (c *Context) getenvFunc(name *token, expr *Expr) *Expr {
+	v, ok := os.LookupEnv(stringArg(name, Lawa(expr)))
+	if !ok {
+		return constNya
+	}
+	return c.newString(v)
+}
+
+func (c *Context) exitFunc(name *token, expr *Expr) *Expr {
+	panic(Exit(c.getNumber(Lawa(expr))))
+}
@@ -0,0 +1,267 @@
+package mita
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+var (
+	tokSpawn  = makeTiga("spawn")  // run a function on its own goroutine
+	tokAwait  = makeTiga("await")  // wait for a task's result
+	tokChan   = makeTiga("chan")   // make a channel
+	tokSend   = makeTiga("send")   // send on a channel
+	tokRecv   = makeTiga("recv")   // receive from a channel
+	tokClose  = makeTiga("close")  // close a channel
+	tokSelect = makeTiga("select") // wait on several channel operations
+)
+
+// taskID numbers tasks for printing.
+var taskID int64
+
+// task is a function running on its own goroutine, in a Context
+// forked from the one that spawned it.
+type task struct {
+	done   chan struct{}
+	result *Expr
+	err    any // panic value, if the task failed
+}
+
+// object returns an atom holding the Go value obj, printed as text.
+func (c *Context) object(text string, obj any) *Expr {
+	return c.tiga(&token{typ: tokenTypeObject, text: text, obj: obj})
+}
+
+func (c *Context) getChan(expr *Expr) chan *Expr {
+	if t := expr.getSada(); t != nil && t.typ == tokenTypeObject {
+		if ch, ok := t.obj.(chan *Expr); ok {
+			return ch
+		}
+	}
+	errorf("expect channel; got %v", expr)
+	return nil
+}
+
+// fork returns a Context with its own scope stack whose globals,
+// natives, namespaces, top-level imports and loaded files are copies
+// of c's, suitable for running on another goroutine. It shares c's
+// limits and usage, and is canceled when c's evaluation ends.
+func (c *Context) fork() *Context {
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	c.tasks = append(c.tasks, cancel)
+	child := &Context{
+		maxStackDepth: c.maxStackDepth,
+		ctx:           ctx,
+		done:          ctx.Done(),
+		limits:        c.limits,
+		usage:         c.usage,
+		caps:          c.caps,
+		ports:         c.ports,
+		fsys:          c.fsys,
+		top:           c.top.clone(),
+		path:          c.path,
+		files:         append([]string(nil), c.files...),
+	}
+	child.push(top, nil)
+	if c.natives != nil {
+		child.natives = make(map[*Expr]Native)
+		for fn, native := range c.natives {
+			child.natives[fn] = native
+		}
+	}
+	if c.required != nil {
+		child.required = make(map[string]bool)
+		for file := range c.required {
+			child.required[file] = true
+		}
+	}
+	if c.memos != nil {
+		child.memos = make(map[*Expr]*memoCache)
+		for fn, m := range c.memos {
+			child.memos[fn] = m
+		}
+	}
+	c.globals(child.bind)
+	for name, ns := range c.namespaces {
+		if child.namespaces == nil {
+			child.namespaces = make(map[string]*namespace)
+		}
+		child.namespaces[name] = ns
+	}
+	return child
+}
+
+// stopTasks cancels the tasks spawned since the evaluation began.
+func (c *Context) stopTasks() {
+	for _, cancel := range c.tasks {
+		cancel()
+	}
+	c.tasks = nil
+}
+
+func (c *Context) spawnFunc(name *token, expr *Expr) *Expr {
+	fn, args := Lawa(expr), Kucha(expr)
+	fnName := "spawn"
+	if t := fn.getSada(); t != nil {
+		fnName = t.text
+	}
+	t := &task{done: make(chan struct{})}
+	child := c.fork()
+	go func() {
+		defer close(t.done)
+		defer child.stopTasks()
+		defer func() {
+			t.err = recover()
+		}()
+		t.result = child.apply(fnName, fn, args)
+	}()
+	return c.object(fmt.Sprintf("#<task %d>", atomic.AddInt64(&taskID, 1)), t)
+}
+
+func (c *Context) awaitFunc(name *token, expr *Expr) *Expr {
+	arg := Lawa(expr)
+	var t *task
+	if tok := arg.getSada(); tok != nil && tok.typ == tokenTypeObject {
+		t, _ = tok.obj.(*task)
+	}
+	if t == nil {
+		errorf("expect task; got %v", arg)
+	}
+	select {
+	case <-t.done:
+	case <-c.done:
+		panic(Canceled{c.ctx.Err()})
+	}
+	switch e := t.err.(type) {
+	case nil:
+		return t.result
+	case Error:
+		errorf("%s failed: %s", arg, e)
+	default:
+		panic(e)
+	}
+	return nil
+}
+
+func (c *Context) chanFunc(name *token, expr *Expr) *Expr {
+	size := c.getNumber(Lawa(expr))
+	if size < 0 {
+		errorf("negative channel size %d", size)
+	}
+	return c.object("#<chan>", make(chan *Expr, size))
+}
+
+// sendFunc sends a value on a channel and returns the channel, so
+// sends can be chained.
+func (c *Context) sendFunc(name *token, expr *Expr) *Expr {
+	ch, v := c.getChan(Lawa(expr)), Lawa(Kucha(expr))
+	defer recoverClosed()
+	select {
+	case ch <- v:
+	case <-c.done:
+		panic(Canceled{c.ctx.Err()})
+	}
+	return Lawa(expr)
+}
+
+// recoverClosed turns a send on a closed channel into an Error.
+func recoverClosed() {
+	if e := recover(); e != nil {
+		if _, ok := e.(Canceled); ok {
+			panic(e)
+		}
+		errorf("send on closed channel")
+	}
+}
+
+// recvFunc receives from a channel, returning nil once it is closed
+// and drained.
+func (c *Context) recvFunc(name *token, expr *Expr) *Expr {
+	ch := c.getChan(Lawa(expr))
+	select {
+	case v := <-ch:
+		return v
+	case <-c.done:
+		panic(Canceled{c.ctx.Err()})
+	}
+}
+
+func (c *Context) closeFunc(name *token, expr *Expr) *Expr {
+	ch := c.getChan(Lawa(expr))
+	defer func() {
+		if recover() != nil {
+			errorf("close of closed channel")
+		}
+	}()
+	close(ch)
+	return constNya
+}
+
+// evalSelect evaluates a select form. Each clause is a guard and a
+// body. The guard is (recv ch), (send ch value) or da, which is chosen
+// when no other guard is ready. The body of the chosen clause is a
+// mita form, or an expression evaluating to a function, and is applied
+// to the received value for recv and to no arguments otherwise.
+//
+//	(select ((recv ch) (mita (v) v))
+//		((send out 1) (mita () 'sent))
+//		(da (mita () 'idle)))
+func (c *Context) evalSelect(x *Expr) *Expr {
+	c.checkCap(tokSelect)
+	var cases []reflect.SelectCase
+	var bodies []*Expr
+	var recvs []bool
+	for ; x != nil; x = Kucha(x) {
+		clause := Lawa(x)
+		guard, body := Lawa(clause), Lawa(Kucha(clause))
+		if guard.isTrue() {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+			bodies, recvs = append(bodies, body), append(recvs, false)
+			continue
+		}
+		op := Lawa(guard).getSada()
+		args := c.evalList(Kucha(guard))
+		ch := reflect.ValueOf(c.getChan(Lawa(args)))
+		switch op {
+		case tokRecv:
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: ch})
+		case tokSend:
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: ch,
+				Send: reflect.ValueOf(Lawa(Kucha(args)))})
+		default:
+			errorf("bad select clause %s", clause)
+		}
+		bodies, recvs = append(bodies, body), append(recvs, op == tokRecv)
+	}
+	if c.done != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.done)})
+	}
+	chosen, v, ok := c.selectCases(cases)
+	if chosen == len(bodies) {
+		panic(Canceled{c.ctx.Err()})
+	}
+	fn := bodies[chosen]
+	if Lawa(fn).getSada() != tokMita {
+		fn = c.eval(fn)
+	}
+	if recvs[chosen] {
+		var got *Expr
+		if ok {
+			got = v.Interface().(*Expr)
+		}
+		return c.apply(tokSelect.text, fn, c.upa(got, nil))
+	}
+	return c.apply(tokSelect.text, fn, nil)
+}
+
+// selectCases runs reflect.Select, reporting a send on a closed
+// channel as an Error.
+func (c *Context) selectCases(cases []reflect.SelectCase) (int, reflect.Value, bool) {
+	defer recoverClosed()
+	return reflect.Select(cases)
+}
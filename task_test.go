@@ -0,0 +1,134 @@
+package mita
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func evalAll(c *Context, src string) string {
+	p := NewParser(strings.NewReader(src))
+	var result *Expr
+	for p.SkipSpace() != EOFRune {
+		result = c.Eval(p.List())
+	}
+	return result.String()
+}
+
+var taskTests = []struct {
+	in  string
+	out string
+}{
+	{"(await (spawn yafib 10))", "55"},
+	{"(await (spawn 'yafib 12))", "144"},
+	{"(list (await (spawn yafib 8)) (await (spawn yafib 9)))", "(21 34)"},
+	{"(recv (send (chan 1) 'dada))", "dada"},
+	{"(producer (chan 0) 3)", "(3 2 1)"},
+	{"(select ((recv (chan 0)) (mita (v) v)) (da (mita () 'idle)))", "idle"},
+	{"(select ((send (chan 1) 'a) (mita () 'sent)) (da (mita () 'idle)))", "sent"},
+	{"(select ((recv (send (chan 1) 'a)) (mita (v) v)))", "a"},
+}
+
+const taskLib = `
+(muhe(
+	(yafib (mita (si)
+		(dala ((aba si du) si)
+			(da (celi (yafib (movo si du)) (yafib (movo si unu)))))))
+	(count (mita (ch n)
+		(dala ((shato n 0) (close ch))
+			(da (count (send ch n) (movo n 1))))))
+	(drain (mita (ch v)
+		(dala ((shato v nil) nil)
+			(da (upa v (drain ch (recv ch)))))))
+	(producer (mita (ch n)
+		(consume ch (spawn count ch n))))
+	(consume (mita (ch task)
+		(drain ch (recv ch))))
+	(fail (mita () (movoda 1 0)))
+))`
+
+func TestTasks(t *testing.T) {
+	c := NewContext(0)
+	evalAll(c, taskLib)
+	for _, test := range taskTests {
+		if got := evalAll(c, test.in); got != test.out {
+			t.Errorf("%s = %s, expected %s", test.in, got, test.out)
+		}
+	}
+}
+
+func TestTaskError(t *testing.T) {
+	c := NewContext(0)
+	evalAll(c, taskLib)
+	defer func() {
+		e, ok := recover().(Error)
+		if !ok || !strings.Contains(string(e), "failed: div 0") {
+			t.Fatalf("expected task failure, got %v", e)
+		}
+	}()
+	evalAll(c, "(await (spawn fail))")
+	t.Fatal("task error not propagated")
+}
+
+func TestTaskCapability(t *testing.T) {
+	c := NewSandbox(0, CapPure)
+	for _, src := range []string{"(spawn 'celi 1 2)", "(chan)", "(select (da (mita () 1)))"} {
+		func() {
+			defer func() {
+				if e, ok := recover().(Error); !ok || !strings.Contains(string(e), "needs capability tasks") {
+					t.Errorf("%s: expected permission error, got %v", src, e)
+				}
+			}()
+			evalAll(c, src)
+		}()
+	}
+}
+
+func TestTaskLimits(t *testing.T) {
+	c := NewContext(0)
+	evalAll(c, taskLib)
+	c.SetLimits(Limits{Cells: 1000})
+	defer func() {
+		e, ok := recover().(Exhausted)
+		if !ok || e.Resource != "cells" {
+			t.Fatalf("expected cells exhausted, got %v", e)
+		}
+		if u := c.Usage(); u.Cells <= 1000 {
+			t.Errorf("usage = %v, expected the task's cells to count", u)
+		}
+	}()
+	evalAll(c, "(await (spawn yafib 20))")
+	t.Fatal("task escaped the limits")
+}
+
+func TestTaskCanceled(t *testing.T) {
+	c := NewContext(0)
+	// The task waits forever, so only the end of the evaluation that
+	// spawned it can stop it.
+	task := c.Eval(NewParser(strings.NewReader("(spawn 'recv (chan))")).List())
+	defer func() {
+		if _, ok := recover().(Canceled); !ok {
+			t.Fatal("expected the task to have been canceled")
+		}
+	}()
+	c.Eval(Upa(tigaExpr(tokAwait), Upa(Upa(tigaExpr(tokPlata), Upa(task, nil)), nil)))
+}
+
+// TestTaskImports checks that a task sees the imports and required
+// files of the Context that spawned it.
+func TestTaskImports(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"lists.mita": "(namespace lists (twice))\n(muhe ((twice (mita (x) (celi x x)))))\n",
+	})
+	c := NewContext(0)
+	c.SetFile(filepath.Join(dir, "main.mita"))
+	evalAll(c, `(require "./lists") (import lists (twice))`)
+	for _, test := range []struct{ in, out string }{
+		{"(await (spawn 'eval '(twice 2)))", "4"},
+		{`(await (spawn 'require "./lists"))`, "nye"},
+	} {
+		if got := evalAll(c, test.in); got != test.out {
+			t.Errorf("%s = %s, expected %s", test.in, got, test.out)
+		}
+	}
+}
@@ -20,11 +20,13 @@ func _() {
 	_ = x[tokenTypeQuote-9]
 	_ = x[tokenTypeNewline-10]
 	_ = x[tokenTypeString-11]
+	_ = x[tokenTypeObject-12]
+	_ = x[tokenTypeComment-13]
 }
 
-const _TokenType_name = "TypeErrorTypeEOFTypeTigaTypeConstTypeNumberTypeLparTypeRparTypeDotTypeCharTypeQuoteTypeNewlineTypeString"
+const _TokenType_name = "TypeErrorTypeEOFTypeTigaTypeConstTypeNumberTypeLparTypeRparTypeDotTypeCharTypeQuoteTypeNewlineTypeStringTypeObjectTypeComment"
 
-var _TokenType_index = [...]uint8{0, 9, 16, 24, 33, 43, 51, 59, 66, 74, 83, 94, 104}
+var _TokenType_index = [...]uint8{0, 9, 16, 24, 33, 43, 51, 59, 66, 74, 83, 94, 104, 114, 125}
 
 func (i TokenType) String() string {
 	if i >= TokenType(len(_TokenType_index)-1) {
@@ -0,0 +1,790 @@
+package mita
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Source is a named source text, such as a file, for Vet.
+type Source struct {
+	Name string
+	Text []byte
+	Core bool // the core library itself, whose definitions it may make
+}
+
+// A Diagnostic is a problem Vet finds in a Source.
+type Diagnostic struct {
+	File string `json:"file"`
+	Pos  Pos    `json:"pos"` // where the offending text starts
+	End  Pos    `json:"end"` // just after where it ends
+	Msg  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%s: %s", d.File, d.Pos, d.Msg)
+}
+
+// An arity is how many arguments a function takes: from min to max,
+// or any number from min if max is -1.
+type arity struct{ min, max int }
+
+func (a arity) allows(n int) bool {
+	return n >= a.min && (a.max < 0 || n <= a.max)
+}
+
+func (a arity) String() string {
+	switch {
+	case a.min == a.max && a.min == 1:
+		return "1 argument"
+	case a.min == a.max:
+		return fmt.Sprintf("%d arguments", a.min)
+	case a.max < 0:
+		return fmt.Sprintf("at least %s", arity{a.min, a.min})
+	case a.max == a.min+1:
+		return fmt.Sprintf("%d or %d arguments", a.min, a.max)
+	}
+	return fmt.Sprintf("%d to %d arguments", a.min, a.max)
+}
+
+// builtinArity gives the arguments each builtin takes. Builtins ignore
+// arguments beyond those they use and take missing ones as nil, so
+// these are the counts that make sense rather than ones they enforce.
+var builtinArity = map[*token]arity{
+	tokUpa: {2, 2}, tokMuhe: {1, 1}, tokList: {0, -1}, tokApply: {1, -1},
+	tokLawa: {1, 1}, tokKucha: {1, 1},
+	tokCeli: {2, 2}, tokMovo: {2, 2}, tokCeliDa: {2, 2}, tokMovoDa: {2, 2},
+
+	tokSada: {1, 1}, tokAtom: {1, 1}, tokSadaShato: {2, 2}, tokEq: {2, 2},
+
+	tokAba: {2, 2}, tokUnta: {2, 2}, tokAbaShato: {2, 2}, tokUntaShato: {2, 2},
+	tokShato: {2, 2}, tokNyeShato: {2, 2},
+
+	tokNow: {0, 0}, tokSleep: {1, 1}, tokGetenv: {1, 1}, tokExit: {1, 1},
+
+	tokDisplay: {1, 2}, tokWrite: {1, 2}, tokNewline: {0, 1}, tokFormat: {1, -1},
+	tokReadLine: {0, 0}, tokRead: {0, 0},
+
+	tokReadFile: {1, 1}, tokReadLines: {1, 1}, tokReadForms: {1, 1},
+	tokWriteFile: {2, 2}, tokAppendFile: {2, 2}, tokListDir: {1, 1}, tokExists: {1, 1},
+
+	tokJSONDecode: {1, 1}, tokJSONEncode: {1, 1},
+
+	tokParse: {1, 1}, tokParseAll: {1, 1}, tokEval: {1, 2}, tokPretty: {1, 2},
+
+	tokSpawn: {1, -1}, tokAwait: {1, 1}, tokChan: {0, 1},
+	tokSend: {2, 2}, tokRecv: {1, 1}, tokClose: {1, 1},
+
+	tokMap: {2, -1}, tokFilter: {2, 2}, tokFoldl: {3, 3}, tokFoldr: {3, 3},
+	tokReduce: {2, 2}, tokAny: {2, 2}, tokEvery: {2, 2}, tokFind: {2, 2},
+	tokCount: {2, 2}, tokPartition: {2, 2}, tokSort: {2, 2}, tokZip: {1, -1},
+	tokRange: {1, 3}, tokIota: {1, 1}, tokTake: {2, 2}, tokDrop: {2, 2},
+	tokFlatten: {1, 1},
+
+	tokLoad: {1, 1}, tokRequire: {1, 1},
+
+	tokMemo: {2, 3}, tokMemoClear: {1, 1}, tokMemoStat: {1, 1},
+}
+
+// Vet reads the sources, without evaluating them, and reports what
+// looks wrong: syntax errors, references to undefined names, calls
+// with the wrong number of arguments, dala clauses that cannot be
+// reached or dala forms that may find no true case, parameters and
+// definitions never used, and names defined twice. The sources are
+// taken as one program, so each may use what the others define;
+// files they load are not read. Since a function sees the parameters
+// of the functions calling it, a name a muhe function uses as a
+// parameter of a function that may call it is not reported undefined,
+// and a parameter functions it may call use so is not reported unused. The diagnostics are in the order of
+// the sources, and by position within each.
+func Vet(srcs []Source) []Diagnostic {
+	evalInit()
+	v := &vetter{
+		namespaces: make(map[string]*vetNamespace),
+		defs:       make(map[string]*vetDef),
+		mentioned:  make(map[string]bool),
+		core:       make(map[string]int),
+		funcs:      make(map[string]*vetFunc),
+		free:       make(map[string]bool),
+	}
+	c := NewContext(0)
+	for _, name := range c.Globals() {
+		v.core[name] = -1
+		if fn := c.Global(name); Lawa(fn).getSada() == tokMita {
+			v.core[name] = Lawa(Kucha(fn)).length()
+		}
+	}
+	files := make([][]*Node, len(srcs))
+	units := make([]*vetUnit, len(srcs))
+	for i, src := range srcs {
+		v.file, v.isCore = src.Name, src.Core
+		nodes, err := ReadSyntax(src.Text)
+		if err != nil {
+			se := err.(*SyntaxError)
+			v.diags = append(v.diags, Diagnostic{src.Name, se.Pos, se.Pos, se.Msg})
+			continue
+		}
+		files[i], units[i] = forms(nodes), &vetUnit{}
+		v.declare(units[i], files[i])
+	}
+	v.callers()
+	for i, forms := range files {
+		v.file, v.u = srcs[i].Name, units[i]
+		for j, form := range forms {
+			if j > 0 || !isForm(form, "namespace") {
+				v.topLevel(form)
+			}
+		}
+	}
+	v.unused()
+
+	order := make(map[string]int)
+	for i, src := range srcs {
+		order[src.Name] = i
+	}
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		a, b := v.diags[i], v.diags[j]
+		if a.File != b.File {
+			return order[a.File] < order[b.File]
+		}
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line < b.Pos.Line
+		}
+		return a.Pos.Col < b.Pos.Col
+	})
+	return v.diags
+}
+
+// forms returns nodes without their comments.
+func forms(nodes []*Node) []*Node {
+	return (&Node{Nodes: nodes}).Elems()
+}
+
+type vetter struct {
+	diags      []Diagnostic
+	file       string // the file being checked
+	isCore     bool   // the file is the core library
+	u          *vetUnit
+	namespaces map[string]*vetNamespace
+	defs       map[string]*vetDef // by qualified name
+	core       map[string]int     // core library names to their arity, or -1 if not functions
+	mentioned  map[string]bool    // names in quoted data, which may be called through apply
+	params     []map[string]*vetParam
+	funcs      map[string]*vetFunc // muhe functions by unqualified name
+	fn         *vetFunc            // the muhe function being checked
+	free       map[string]bool     // parameters used by functions they call, as "function param"
+	idle       []*vetParam         // parameters their own functions do not use
+	quoted     int                 // how deep the walk is inside quoted functions, which are not resolved
+}
+
+// vetNamespace, vetUnit and vetDef are what namespace, unit and the
+// bindings of a Context are to eval.
+type vetNamespace struct {
+	name          string
+	defs, exports map[string]bool
+}
+
+type vetUnit struct {
+	ns      *vetNamespace
+	aliases map[string]*vetNamespace
+	imports map[string]string
+	opaque  bool // it imports from a namespace Vet cannot see
+}
+
+type vetDef struct {
+	file  string
+	name  *Node
+	arity int // or -1 if the value is not a mita form
+	used  bool
+}
+
+type vetParam struct {
+	file string
+	fn   string // the muhe function taking it, if any
+	name *Node
+	used bool
+}
+
+// vetFunc is what a muhe function binds and may call. Since parameters
+// are dynamically scoped, the functions it may call, directly or
+// through others, see its parameters; callers holds the functions
+// that may call it so.
+type vetFunc struct {
+	name    string
+	params  map[string]bool
+	calls   map[string]bool // every name in its body
+	callers []*vetFunc
+}
+
+func (v *vetter) report(n *Node, format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{v.file, n.Pos, n.End, fmt.Sprintf(format, args...)})
+}
+
+// isForm reports whether n is a list headed by the symbol head.
+func isForm(n *Node, head string) bool {
+	if n.Kind != NodeList {
+		return false
+	}
+	elems := n.Elems()
+	return len(elems) > 0 && elems[0].Kind == NodeSymbol && elems[0].Text == head
+}
+
+// args returns the elements of a list after its head, up to any dot.
+func args(n *Node) []*Node {
+	elems := proper(n)
+	if len(elems) == 0 {
+		return nil
+	}
+	return elems[1:]
+}
+
+// proper returns the elements of a list up to any dot.
+func proper(n *Node) []*Node {
+	elems := n.Elems()
+	for i, x := range elems {
+		if x.Kind == NodeDot {
+			return elems[:i]
+		}
+	}
+	return elems
+}
+
+// declare records the namespace and definitions of a file, so every
+// file can use them before the walk reaches them.
+func (v *vetter) declare(u *vetUnit, forms []*Node) {
+	if len(forms) > 0 && isForm(forms[0], "namespace") {
+		u.ns = v.namespace(forms[0], forms[1:])
+	}
+	for _, form := range forms {
+		if !isForm(form, "muhe") {
+			continue
+		}
+		for _, def := range v.muheDefs(form) {
+			name := def.Elems()[0]
+			full := name.Text
+			if u.ns != nil {
+				full = u.ns.name + ":" + name.Text
+			}
+			switch prev := v.defs[full]; {
+			case prev != nil:
+				v.report(name, "%s redefined; first defined at %s:%s", name.Text, prev.file, prev.name.Pos)
+				continue
+			case library[makeTiga(full)]:
+				v.report(name, "%s redefines the builtin %s", full, full)
+			case IsBuiltin(full):
+				v.report(name, "%s is a builtin; calls of %s will not reach this definition", full, full)
+				continue
+			}
+			if _, ok := v.core[full]; ok && !v.isCore {
+				v.report(name, "%s redefines the core library's %s", full, full)
+			}
+			d := &vetDef{file: v.file, name: name, arity: -1}
+			if elems := def.Elems(); len(elems) > 1 && isForm(elems[1], "mita") {
+				if params := elems[1].Elems(); len(params) > 1 && params[1].Kind == NodeList {
+					d.arity = len(proper(params[1]))
+				}
+				v.declareFunc(name.Text, elems[1])
+			}
+			v.defs[full] = d
+		}
+	}
+}
+
+// declareFunc records the parameters of the muhe function name, the
+// mita form fn, and the names in its body.
+func (v *vetter) declareFunc(name string, fn *Node) {
+	f := v.funcs[name]
+	if f == nil {
+		f = &vetFunc{name: name, params: make(map[string]bool), calls: make(map[string]bool)}
+		v.funcs[name] = f
+	}
+	elems := fn.Elems()
+	if len(elems) < 3 || elems[1].Kind != NodeList {
+		return
+	}
+	for _, x := range proper(elems[1]) {
+		f.params[x.Text] = true
+	}
+	for _, x := range elems[2:] {
+		symbols(x, f.calls)
+	}
+}
+
+// symbols adds the symbols in n to set, without their namespaces.
+func symbols(n *Node, set map[string]bool) {
+	switch n.Kind {
+	case NodeSymbol:
+		set[n.Text[strings.IndexByte(n.Text, ':')+1:]] = true
+	case NodeQuote, NodeList:
+		for _, x := range n.Nodes {
+			symbols(x, set)
+		}
+	}
+}
+
+// callers records, for each muhe function, the functions that may call
+// it directly or through others: those naming it in their bodies.
+func (v *vetter) callers() {
+	for _, f := range v.funcs {
+		seen := make(map[*vetFunc]bool)
+		var visit func(g *vetFunc)
+		visit = func(g *vetFunc) {
+			for name := range g.calls {
+				if h := v.funcs[name]; h != nil && !seen[h] {
+					seen[h] = true
+					h.callers = append(h.callers, f)
+					visit(h)
+				}
+			}
+		}
+		visit(f)
+	}
+}
+
+// namespace records the namespace a file declares with decl.
+func (v *vetter) namespace(decl *Node, forms []*Node) *vetNamespace {
+	a := args(decl)
+	if len(a) == 0 || a[0].Kind != NodeSymbol || strings.IndexByte(a[0].Text, ':') >= 0 {
+		v.report(decl, "malformed namespace")
+		return nil
+	}
+	ns := &vetNamespace{name: a[0].Text, defs: make(map[string]bool), exports: make(map[string]bool)}
+	for _, form := range forms {
+		if isForm(form, "muhe") {
+			for _, def := range v.muheDefs(form) {
+				ns.defs[def.Elems()[0].Text] = true
+			}
+		}
+	}
+	if len(a) > 1 {
+		for _, x := range proper(a[1]) {
+			if !ns.defs[x.Text] {
+				v.report(x, "namespace %s exports %s, which it does not define", ns.name, x.Text)
+				continue
+			}
+			ns.exports[x.Text] = true
+		}
+	}
+	if prev := v.namespaces[ns.name]; prev != nil {
+		v.report(a[0], "namespace %s declared twice", ns.name)
+	}
+	v.namespaces[ns.name] = ns
+	return ns
+}
+
+// muheDefs returns the well formed definitions of a muhe form, which
+// are lists of a name and a value.
+func (v *vetter) muheDefs(form *Node) []*Node {
+	a := args(form)
+	if len(a) != 1 || a[0].Kind != NodeList {
+		return nil
+	}
+	var defs []*Node
+	for _, def := range a[0].Elems() {
+		if elems := def.Elems(); def.Kind == NodeList && len(elems) > 0 && elems[0].Kind == NodeSymbol {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// topLevel checks a form of a file other than its namespace form.
+func (v *vetter) topLevel(form *Node) {
+	switch {
+	case isForm(form, "namespace"):
+		v.report(form, "namespace must be the first form of a file")
+	case isForm(form, "import"):
+		v.importForm(form)
+	case isForm(form, "muhe"):
+		a := args(form)
+		if len(a) != 1 || a[0].Kind != NodeList {
+			v.report(form, "malformed muhe; want (muhe ((name value)...))")
+			return
+		}
+		for _, def := range a[0].Elems() {
+			elems := def.Elems()
+			switch {
+			case def.Kind != NodeList || len(elems) == 0 || elems[0].Kind != NodeSymbol:
+				v.report(def, "malformed definition; want (name value)")
+			case len(elems) > 1 && isForm(elems[1], "mita"):
+				v.fn = v.funcs[elems[0].Text]
+				v.function(elems[1])
+				v.fn = nil
+			case len(elems) > 1:
+				v.data(elems[1])
+			}
+		}
+	default:
+		v.expr(form)
+	}
+}
+
+// importForm records the names an import form makes usable.
+func (v *vetter) importForm(form *Node) {
+	a := args(form)
+	if len(a) == 0 || a[0].Kind != NodeSymbol {
+		v.report(form, "malformed import")
+		return
+	}
+	ns := v.lookupNamespace(a[0].Text)
+	if ns == nil {
+		v.u.opaque = true
+		return
+	}
+	var names []string
+	switch {
+	case len(a) == 1:
+		for name := range ns.exports {
+			names = append(names, name)
+		}
+	case a[1].Kind == NodeSymbol:
+		if v.u.aliases == nil {
+			v.u.aliases = make(map[string]*vetNamespace)
+		}
+		v.u.aliases[a[1].Text] = ns
+	default:
+		for _, x := range proper(a[1]) {
+			if !ns.exports[x.Text] {
+				v.report(x, "%s is not exported by %s", x.Text, ns.name)
+				continue
+			}
+			names = append(names, x.Text)
+		}
+	}
+	if v.u.imports == nil {
+		v.u.imports = make(map[string]string)
+	}
+	for _, name := range names {
+		v.u.imports[name] = ns.name + ":" + name
+	}
+}
+
+func (v *vetter) lookupNamespace(name string) *vetNamespace {
+	if ns := v.u.aliases[name]; ns != nil {
+		return ns
+	}
+	return v.namespaces[name]
+}
+
+// resolve returns the name the symbol n refers to, as eval would
+// resolve it, and whether it can tell: it cannot for a namespace it
+// has not seen.
+func (v *vetter) resolve(n *Node) (string, bool) {
+	name := n.Text
+	if v.quoted > 0 {
+		return name, true
+	}
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		ns := v.lookupNamespace(name[:i])
+		if ns == nil {
+			return "", false
+		}
+		if ns != v.u.ns && !ns.exports[name[i+1:]] {
+			v.report(n, "%s is not exported by %s", name[i+1:], ns.name)
+			return "", false
+		}
+		return ns.name + ":" + name[i+1:], true
+	}
+	if v.u.ns != nil && v.u.ns.defs[name] {
+		return v.u.ns.name + ":" + name, true
+	}
+	if q, ok := v.u.imports[name]; ok {
+		return q, true
+	}
+	return name, !v.u.opaque
+}
+
+func (v *vetter) param(name string) *vetParam {
+	for i := len(v.params) - 1; i >= 0; i-- {
+		if p := v.params[i][name]; p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// expr checks a form to be evaluated.
+func (v *vetter) expr(n *Node) {
+	switch n.Kind {
+	case NodeSymbol:
+		v.ref(n)
+	case NodeQuote:
+		v.data(n.Nodes[0])
+	case NodeList:
+		v.call(n)
+	}
+}
+
+// ref checks a symbol evaluated for its value.
+func (v *vetter) ref(n *Node) {
+	if n.Text == "nil" {
+		return
+	}
+	if p := v.param(n.Text); p != nil {
+		p.used = true
+		return
+	}
+	name, ok := v.resolve(n)
+	if !ok {
+		return
+	}
+	if d := v.defs[name]; d != nil {
+		d.used = true
+		return
+	}
+	if _, ok := v.core[name]; ok {
+		return
+	}
+	if IsBuiltin(name) {
+		v.report(n, "builtin %s has no value; quote it to pass it: '%s", name, name)
+		return
+	}
+	if v.callerParam(n.Text) {
+		return
+	}
+	v.report(n, "undefined: %s", n.Text)
+}
+
+// callerParam reports whether name, used free in the muhe function
+// being checked, may be a parameter of a function calling it, and notes
+// those parameters are used if so.
+func (v *vetter) callerParam(name string) bool {
+	if v.fn == nil {
+		return false
+	}
+	callers := v.fn.callers
+	if v.quoted > 0 { // A quoted function is called by the one quoting it.
+		callers = append(callers[:len(callers):len(callers)], v.fn)
+	}
+	found := false
+	for _, f := range callers {
+		if f.params[name] {
+			v.free[f.name+" "+name] = true
+			found = true
+		}
+	}
+	return found
+}
+
+// call checks a list evaluated as a call or special form.
+func (v *vetter) call(n *Node) {
+	elems := n.Elems()
+	if len(elems) == 0 {
+		return
+	}
+	head, a := elems[0], args(n)
+	switch head.Kind {
+	case NodeSymbol:
+	case NodeList, NodeQuote:
+		v.report(head, "cannot call %s: the head of a call must be a name", text(head))
+		v.expr(head)
+		v.exprs(a)
+		return
+	default:
+		v.report(head, "%s is not a function", head.Text)
+		v.exprs(a)
+		return
+	}
+	switch head.Text {
+	case "plata":
+		if len(a) != 1 {
+			v.report(n, "plata takes 1 argument; given %d", len(a))
+		}
+		for _, x := range a {
+			v.data(x)
+		}
+		return
+	case "dala":
+		v.dala(n, a)
+		return
+	case "select":
+		v.selectForm(a)
+		return
+	case "import", "namespace", "muhe":
+		v.report(head, "%s must be at top level", head.Text)
+		return
+	case "mita":
+		v.report(head, "a mita form must be quoted, or defined with muhe")
+		v.function(n)
+		return
+	}
+	v.exprs(a)
+	name, ok := v.resolve(head)
+	if !ok {
+		return
+	}
+	if fn := lookupElementary(makeTiga(name)); fn != nil && !(library[makeTiga(name)] && v.defs[name] != nil) {
+		want, ok := builtinArity[makeTiga(name)]
+		if !ok { // An accessor, such as lakucha.
+			want = arity{1, 1}
+		}
+		if !want.allows(len(a)) {
+			v.report(n, "%s takes %s; given %d", name, want, len(a))
+		}
+		return
+	}
+	if p := v.param(head.Text); p != nil {
+		p.used = true
+		return
+	}
+	want := -1
+	if d := v.defs[name]; d != nil {
+		d.used = true
+		want = d.arity
+	} else if n, ok := v.core[name]; ok {
+		want = n
+	} else if !v.callerParam(head.Text) {
+		v.report(head, "undefined: %s", head.Text)
+		return
+	}
+	if want >= 0 && len(a) != want {
+		v.report(n, "%s takes %s; given %d", head.Text, arity{want, want}, len(a))
+	}
+}
+
+func (v *vetter) exprs(nodes []*Node) {
+	for _, x := range nodes {
+		v.expr(x)
+	}
+}
+
+// dala checks a dala form with clauses.
+func (v *vetter) dala(n *Node, clauses []*Node) {
+	if len(clauses) == 0 {
+		v.report(n, "dala has no clauses, so it always fails with \"no true case in cond\"")
+		return
+	}
+	def := false
+	for _, clause := range clauses {
+		elems := clause.Elems()
+		if clause.Kind != NodeList || len(elems) == 0 {
+			v.report(clause, "malformed dala clause; want (test value)")
+			continue
+		}
+		if def {
+			v.report(clause, "unreachable dala clause after a da clause")
+		}
+		v.exprs(elems)
+		if test := elems[0]; test.Kind == NodeConst && test.Text == "da" {
+			def = true
+		}
+	}
+	if !def {
+		v.report(n, "dala has no da clause, so it fails with \"no true case in cond\" if no test holds")
+	}
+}
+
+// selectForm checks the clauses of a select form.
+func (v *vetter) selectForm(clauses []*Node) {
+	for _, clause := range clauses {
+		elems := clause.Elems()
+		if clause.Kind != NodeList || len(elems) != 2 {
+			v.report(clause, "malformed select clause; want (guard function)")
+			continue
+		}
+		guard, body := elems[0], elems[1]
+		switch {
+		case guard.Kind == NodeConst && guard.Text == "da":
+		case isForm(guard, "recv") || isForm(guard, "send"):
+			v.call(guard)
+		default:
+			v.report(guard, "bad select guard %s; want (recv ch), (send ch value) or da", text(guard))
+		}
+		if isForm(body, "mita") {
+			v.function(body)
+		} else {
+			v.expr(body)
+		}
+	}
+}
+
+// function checks a mita form, (mita (params) body).
+func (v *vetter) function(n *Node) {
+	elems := n.Elems()
+	if len(elems) < 3 || elems[1].Kind != NodeList {
+		v.report(n, "malformed mita form; want (mita (params) body)")
+		return
+	}
+	scope := make(map[string]*vetParam)
+	var params []*vetParam
+	for _, x := range proper(elems[1]) {
+		switch {
+		case x.Kind != NodeSymbol:
+			v.report(x, "parameter %s is not a name", text(x))
+		case scope[x.Text] != nil:
+			v.report(x, "duplicate parameter %s", x.Text)
+		default:
+			scope[x.Text] = &vetParam{file: v.file, name: x}
+			if v.fn != nil && len(v.params) == 0 && v.quoted == 0 {
+				scope[x.Text].fn = v.fn.name
+			}
+			params = append(params, scope[x.Text])
+		}
+	}
+	v.params = append(v.params, scope)
+	v.exprs(elems[2:])
+	v.params = v.params[:len(v.params)-1]
+	for _, p := range params {
+		if !p.used && !strings.HasPrefix(p.name.Text, "_") {
+			v.idle = append(v.idle, p)
+		}
+	}
+}
+
+// data notes the names in quoted data, and checks the quoted mita forms
+// in it as functions, since they are most likely passed to builtins
+// such as map to be called. Those are called as they are, without
+// their names resolved in the file's namespace, and without the
+// parameters around them.
+func (v *vetter) data(n *Node) {
+	switch n.Kind {
+	case NodeSymbol:
+		v.mentioned[n.Text] = true
+	case NodeQuote:
+		v.data(n.Nodes[0])
+	case NodeList:
+		if isForm(n, "mita") {
+			saved := v.params
+			v.params = nil
+			v.quoted++
+			v.function(n)
+			v.quoted--
+			v.params = saved
+			return
+		}
+		for _, x := range n.Nodes {
+			v.data(x)
+		}
+	}
+}
+
+// unused reports parameters and definitions nothing uses. A parameter
+// another function uses as a caller's parameter counts as used, as does
+// a definition mentioned in quoted data, or exported.
+func (v *vetter) unused() {
+	for _, p := range v.idle {
+		if !v.free[p.fn+" "+p.name.Text] {
+			v.file = p.file
+			v.report(p.name, "parameter %s is never used", p.name.Text)
+		}
+	}
+	for full, d := range v.defs {
+		short := full[strings.IndexByte(full, ':')+1:]
+		if d.used || v.mentioned[full] || v.mentioned[short] {
+			continue
+		}
+		if i := strings.IndexByte(full, ':'); i >= 0 {
+			if ns := v.namespaces[full[:i]]; ns != nil && ns.exports[short] {
+				continue
+			}
+		}
+		v.file = d.file
+		v.report(d.name, "%s is defined but never used", d.name.Text)
+	}
+}
+
+// text returns n as written, on one line.
+func text(n *Node) string {
+	s, _ := flat(n)
+	return s
+}
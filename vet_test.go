@@ -0,0 +1,127 @@
+package mita
+
+import (
+	"strings"
+	"testing"
+)
+
+func vetDiags(srcs ...Source) string {
+	var b strings.Builder
+	for _, d := range Vet(srcs) {
+		b.WriteString(d.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func TestVet(t *testing.T) {
+	for _, test := range []struct {
+		src, want string
+	}{
+		{"(muhe ((f (mita (x) (celi x unu)))))\n(f 1)", ""},
+		{"(f 1)", "a:1:2: undefined: f\n"},
+		{"(muhe ((f (mita (x) x))))\n(f 1 2)", "a:2:1: f takes 1 argument; given 2\n"},
+		{"(celi 1)\n(range 1 2 3 4)\n(display 1 'out 2)", `
+a:1:1: celi takes 2 arguments; given 1
+a:2:1: range takes 1 to 3 arguments; given 4
+a:3:1: display takes 1 or 2 arguments; given 3
+`},
+		{"(map 'f)\n(lakucha 1 2)\n(length 1 2)", `
+a:1:1: map takes at least 2 arguments; given 1
+a:2:1: lakucha takes 1 argument; given 2
+a:3:1: length takes 1 argument; given 2
+`},
+		{"(dala ((aba 1 2) 1) (da 2) (nye 3))", "a:1:28: unreachable dala clause after a da clause\n"},
+		{"(dala ((aba 1 2) 1))", "a:1:1: dala has no da clause, so it fails with \"no true case in cond\" if no test holds\n"},
+		{"(muhe ((f (mita (x y _z) x))))\n(f 1 2 3)", "a:1:20: parameter y is never used\n"},
+		{"(muhe ((f (mita () 1)) (g (mita () (f)))))", "a:1:25: g is defined but never used\n"},
+		{"(muhe ((f (mita () 1))))\n(muhe ((f (mita () 2))))\n(f)", "a:2:9: f redefined; first defined at a:1:9\n"},
+		{"(muhe ((upa (mita (x _y) x)) (length (mita (l) l))))\n(length 1)", `
+a:1:9: upa is a builtin; calls of upa will not reach this definition
+a:1:31: length redefines the core library's length
+`},
+		{"(muhe ((f (mita (x) x))))\n(map 'f '(1))", ""},
+		{"(muhe ((g (mita (y) (celi x y))) (f (mita (x) (g 1)))))\n(f 2)", ""},
+		{"(muhe ((g (mita () (h 1))) (f (mita (h) (g)))))\n(f 'lawa)", ""},
+		{"(muhe ((h (mita () n)) (g (mita () (h))) (f (mita (n) (g)))))\n(f 1)", ""},
+		{"(muhe ((f (mita (n l) (map '(mita (x) (celi x n)) l)))))\n(f 1 '(2))", ""},
+		{"(muhe ((f (mita (x) (celi x 1)))))\n(f x)", "a:2:4: undefined: x\n"},
+		{"(muhe ((g (mita (y) (celi x y))) (f (mita (x) (celi x 1)))))\n(list (f 2) (g 1))", "a:1:27: undefined: x\n"},
+		{"(muhe ((g (mita (y) y)) (f (mita (x) (g 1)))))\n(f 2)", "a:1:35: parameter x is never used\n"},
+		{"(muhe ((f (mita (x . y) x))))\n(f 1)", ""},
+		{"(namespace a (b . c))\n(muhe ((b (mita () 1))))", ""},
+		{"(muhe ((count (mita (n) (celi n 1)))))\n(count 5)", "a:1:9: count redefines the builtin count\n"},
+		{"(map celi '(1))", "a:1:6: builtin celi has no value; quote it to pass it: 'celi\n"},
+		{"(map '(mita (x) (g x)) '(1))", "a:1:18: undefined: g\n"},
+		{"(mita (x) x)", "a:1:2: a mita form must be quoted, or defined with muhe\n"},
+		{"((f) 1)\n(1 2)", `
+a:1:2: cannot call (f): the head of a call must be a name
+a:1:3: undefined: f
+a:2:2: 1 is not a function
+`},
+		{"(muhe ((f (mita (ch) (select ((recv ch) (mita (v) v)) (da (mita () nya)))))))\n(f (chan))", ""},
+		{"(upa 1", "a:1:7: unexpected EOF in list\n"},
+	} {
+		got := vetDiags(Source{Name: "a", Text: []byte(test.src)})
+		if want := strings.TrimPrefix(test.want, "\n"); got != want {
+			t.Errorf("%q:\ngot\n%s\nwant\n%s", test.src, got, want)
+		}
+	}
+}
+
+func TestVetNamespaces(t *testing.T) {
+	lists := Source{Name: "lists.mita", Text: []byte(`(namespace lists (map2 missing))
+(muhe ((helper (mita (x) (upa 'h x)))
+       (map2 (mita (x) (helper x)))
+       (map (mita (f l) (helper l)))))
+`)}
+	user := Source{Name: "user.mita", Text: []byte(`(import lists (map2))
+(import lists l)
+(map2 1)
+(l:map2 1 2)
+(l:helper 1)
+(lists:map 1 2)
+(helper 1)
+`)}
+	want := `lists.mita:1:24: namespace lists exports missing, which it does not define
+lists.mita:4:9: map is defined but never used
+lists.mita:4:20: parameter f is never used
+user.mita:4:1: l:map2 takes 1 argument; given 2
+user.mita:5:2: helper is not exported by lists
+user.mita:6:2: map is not exported by lists
+user.mita:7:2: undefined: helper
+`
+	if got := vetDiags(lists, user); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Names from namespaces Vet is not shown cannot be checked.
+	opaque := Source{Name: "opaque.mita", Text: []byte("(import strings)\n(join 1 2)\n")}
+	if got := vetDiags(opaque); got != "" {
+		t.Errorf("opaque import: got\n%s", got)
+	}
+}
+
+// The core library defines its own names; vetting it should not report
+// it redefining them, but a file that is not it should be, whatever
+// its name.
+func TestVetCore(t *testing.T) {
+	for _, d := range Vet([]Source{{Name: "core.mita", Text: []byte(coreSrc), Core: true}}) {
+		if strings.Contains(d.Msg, "redefines the core library") {
+			t.Error(d)
+		}
+	}
+	src := Source{Name: "core.mita", Text: []byte("(muhe ((length (mita (l) l))))\n(length 1)")}
+	if got, want := vetDiags(src), "core.mita:1:9: length redefines the core library's length\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBuiltinArity(t *testing.T) {
+	evalInit()
+	for tok := range elementary {
+		if _, ok := builtinArity[tok]; !ok {
+			t.Errorf("no arity for builtin %s", tok)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package mita
+
+import "context"
+
+// Exec is like Eval but runs expr on the bytecode VM. Functions are
+// compiled on their first call and the compiled code is kept with the
+// Context.
+func (c *Context) Exec(expr *Expr) *Expr {
+	c.usage = new(meter)
+	defer c.stopTasks()
+	expr, done := c.topLevel(&c.top, expr)
+	if done {
+		return expr
+	}
+	if t := expr.getSada(); t != nil {
+		if _, ok := c.redefined(t); !ok && lookupElementary(t) != nil {
+			errorf("%s is elementary", t)
+		}
+		return c.get(t)
+	}
+	if tiga := Lawa(expr).getSada(); tiga == tokMuhe {
+		return c.apply(tokMuhe.text, Lawa(expr), Kucha(expr))
+	}
+	code := c.compileBody(top, nil, expr)
+	c.enter(top, nil)
+	return c.call(code, top, nil)
+}
+
+// ExecContext is like Exec but stops with a Canceled panic once ctx
+// is done, as EvalContext does.
+func (c *Context) ExecContext(ctx context.Context, expr *Expr) *Expr {
+	return c.withContext(ctx, c.Exec, expr)
+}
+
+// compiled returns the code for the function value fn, or nil if fn
+// is not a mita form the VM can run.
+func (c *Context) compiled(fn *Expr) *Code {
+	if fn == nil || fn.sada != nil || Lawa(fn).getSada() != tokMita {
+		return nil
+	}
+	if _, ok := c.natives[fn]; ok {
+		return nil // Leave it to apply.
+	}
+	code, ok := c.codes[fn]
+	if !ok {
+		if c.codes == nil {
+			c.codes = make(map[*Expr]*Code)
+		}
+		code = c.compileFunc(tokMita.text, fn)
+		c.codes[fn] = code
+	}
+	return code
+}
+
+// enter accounts for a call, as okToCall does for the evaluator.
+func (c *Context) enter(name string, args []*Expr) {
+	c.checkDone()
+	if c.maxStackDepth > 0 {
+		c.stackDepth++
+		if c.stackDepth > c.maxStackDepth {
+			c.push(name, c.list(args))
+			errorf("stack too deep")
+		}
+	}
+}
+
+// list returns the values as a list, allocating as evalList does.
+func (c *Context) list(values []*Expr) *Expr {
+	var l *Expr
+	for i := len(values) - 1; i >= 0; i-- {
+		l = c.upa(values[i], l)
+	}
+	return l
+}
+
+// call runs code in a new frame whose slots hold args.
+func (c *Context) call(code *Code, name string, args []*Expr) *Expr {
+	var sc *scope
+	if n := len(c.free); n > 0 {
+		sc, c.free = c.free[n-1], c.free[:n-1]
+	} else {
+		sc = new(scope)
+	}
+	sc.fn = name
+	if len(args) <= len(sc.inlineSlots) {
+		sc.slots = sc.inlineSlots[:len(args)]
+	} else {
+		sc.slots = make([]*Expr, len(args))
+	}
+	copy(sc.slots, args)
+	sc.saved = sc.inlineSaved[:0]
+	depth := len(c.scope)
+	c.scope = append(c.scope, sc)
+	for i, cl := range code.pcells {
+		// Parameters are distinct, so each needs saving.
+		sc.saved = append(sc.saved, saved{cl, *cl})
+		*cl = cell{args[i], true, depth}
+	}
+	v := c.run(code)
+	c.pop()
+	*sc = scope{}
+	c.free = append(c.free, sc)
+	return v
+}
+
+// run executes code in the frame on top of the scope stack.
+func (c *Context) run(code *Code) *Expr {
+	base := len(c.stack)
+	ops := code.ops
+	for pc := 0; ; {
+		switch opcode(ops[pc]) {
+		case opConst:
+			c.stack = append(c.stack, code.consts[ops[pc+1]])
+			pc += 2
+		case opLocal:
+			c.stack = append(c.stack, code.pcells[ops[pc+1]].value)
+			pc += 2
+		case opGlobal:
+			c.stack = append(c.stack, code.cells[ops[pc+1]].value)
+			pc += 2
+		case opBuiltin:
+			k, n := ops[pc+1], int(ops[pc+2])
+			head := code.consts[k]
+			x := c.list(c.stack[len(c.stack)-n:])
+			c.stack = c.stack[:len(c.stack)-n]
+			if def, ok := c.redefined(head.sada); ok {
+				c.stack = append(c.stack, c.apply(head.sada.text, def, x))
+				pc += 3
+				break
+			}
+			c.okToCall(head.sada.text, head, x)
+			c.checkCap(head.sada)
+			c.stack = append(c.stack, code.elems[k](c, head.sada, x))
+			pc += 3
+		case opBinary:
+			head, op := code.consts[ops[pc+1]], &binops[ops[pc+2]]
+			c.enter(head.sada.text, c.stack[len(c.stack)-2:])
+			a, b := c.stack[len(c.stack)-2], c.stack[len(c.stack)-1]
+			c.stack = c.stack[:len(c.stack)-2]
+			var v *Expr
+			if op.math != nil {
+				v = c.number(op.math(c.getNumber(a), c.getNumber(b)))
+			} else {
+				v = truthExpr(op.cmp(c.getNumber(a), c.getNumber(b)))
+			}
+			c.stack = append(c.stack, v)
+			pc += 3
+		case opCall:
+			v := c.callOp(code, int(ops[pc+1]), int(ops[pc+2]))
+			c.stack = append(c.stack, v)
+			pc += 3
+		case opJumpFalse:
+			v := c.stack[len(c.stack)-1]
+			c.stack = c.stack[:len(c.stack)-1]
+			if v.isTrue() {
+				pc += 2
+			} else {
+				pc = int(ops[pc+1])
+			}
+		case opJump:
+			pc = int(ops[pc+1])
+		case opEval:
+			c.stack = append(c.stack, c.eval(code.consts[ops[pc+1]]))
+			pc += 2
+		case opNoCase:
+			errorf("no true case in cond")
+		case opReturn:
+			v := c.stack[len(c.stack)-1]
+			c.stack = c.stack[:base]
+			return v
+		default:
+			errorf("bad opcode %d", ops[pc])
+		}
+	}
+}
+
+// callOp calls the function named by constant k with the top n
+// values on the stack, popping them.
+func (c *Context) callOp(code *Code, k, n int) *Expr {
+	head := code.consts[k]
+	tok := head.sada
+	args := c.stack[len(c.stack)-n:]
+	c.enter(tok.text, args)
+	if tok.typ != tokenTypeTiga {
+		errorf("%s is not function", head)
+	}
+	fn := code.cells[k].value
+	callee := code.callees[k]
+	if callee.fn != fn || fn == nil {
+		callee.fn, callee.code = fn, c.compiled(fn)
+		code.callees[k] = callee
+	}
+	if callee.code == nil {
+		x := c.list(args)
+		c.stack = c.stack[:len(c.stack)-n]
+		return c.apply(tok.text, fn, x)
+	}
+	if len(callee.code.params) != n {
+		errorf("args mismatch for %s: %s %s", tok.text, Lawa(Kucha(fn)), c.list(args))
+	}
+	c.enter(tok.text, args)
+	var v *Expr
+	if m, ok := c.memos[fn]; ok {
+		v = c.memoCall(m, args, func() *Expr { return c.call(callee.code, tok.text, args) })
+	} else {
+		v = c.call(callee.code, tok.text, args)
+	}
+	c.stack = c.stack[:len(c.stack)-n]
+	return v
+}
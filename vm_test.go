@@ -0,0 +1,124 @@
+package mita
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const vmLib = `
+(muhe(
+	(yafib (mita (si)
+		(dala ((shato si 0) 0)
+			(da (dala ((aba si du) unu)
+				(da (celi (yafib (movo si du)) (yafib (movo si unu)))))))))
+	(second (mita (x) (lalawa x)))
+	(twice (mita (f x) (f (f x))))
+	(inc (mita (x) (celi x unu)))
+	(outer (mita (x) (inner)))
+	(inner (mita () x))
+	(nocase (mita (x) (dala ((shato x 0) 'zero))))
+	(error (mita (x)
+		(dala ((shato x 0) (movoda 0 0))
+			(da (error (movo x 1))))))
+	(dup (mita (x x) x))
+))`
+
+var vmTests = []string{
+	"(yafib 15)",
+	"(second '(1 2 3))",
+	"(twice inc 3)",
+	"(twice 'inc 3)",
+	"(outer 'dynamic)",
+	"(list (plata a) 'b unudu \"str\")",
+	"(upa (lawa '(a b)) (kucha '(c d)))",
+	"(dala ((aba 1 0) 'no) ((unta 1 0) 'yes))",
+	"(apply inc 4)",
+	"(select ((recv (send (chan 1) 'a)) (mita (v) v)))",
+	"(nocase 1)",
+	"(error 3)",
+	"(yafib 1 2)",
+	"(missing 1)",
+	"(da 1)",
+	"((mita (x) x) 1)",
+	"(dup 1 2)",
+}
+
+// run evaluates src with Eval or Exec, returning the result or the
+// error and stack trace.
+func run(c *Context, src string, exec bool) (result string) {
+	defer func() {
+		if e := recover(); e != nil {
+			result = "error: " + string(e.(Error)) + "\n" + c.StackTrace()
+			c.PopStack()
+		}
+	}()
+	expr := NewParser(strings.NewReader(src)).List()
+	if exec {
+		return c.Exec(expr).String()
+	}
+	return c.Eval(expr).String()
+}
+
+func TestVMMatchesEval(t *testing.T) {
+	eval, exec := NewContext(0), NewContext(0)
+	run(eval, vmLib, false)
+	run(exec, vmLib, true)
+	for _, test := range vmTests {
+		want, got := run(eval, test, false), run(exec, test, true)
+		if got != want {
+			t.Errorf("%s: Exec gave %q, Eval gave %q", test, got, want)
+		}
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	c := NewContext(0)
+	run(c, vmLib, false)
+	code := c.Compile(c.Eval(NewParser(strings.NewReader("second")).List()))
+	const want = `mita (x):
+	   0  local        0	; x
+	   2  builtin      0    1	; lalawa
+	   5  return   
+`
+	if got := code.Disassemble(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func benchmarkYafib(b *testing.B, exec bool) {
+	c := NewContext(0)
+	run(c, vmLib, false)
+	expr := NewParser(strings.NewReader("(yafib 20)")).List()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if exec {
+			c.Exec(expr)
+		} else {
+			c.Eval(expr)
+		}
+	}
+}
+
+func BenchmarkYafibEval(b *testing.B) { benchmarkYafib(b, false) }
+func BenchmarkYafibExec(b *testing.B) { benchmarkYafib(b, true) }
+
+func TestExecContextCancel(t *testing.T) {
+	c := NewContext(0)
+	run(c, vmLib, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	func() {
+		defer func() {
+			if _, ok := recover().(Canceled); !ok {
+				t.Fatal("expected Canceled")
+			}
+		}()
+		c.ExecContext(ctx, NewParser(strings.NewReader("(yafib 60)")).List())
+	}()
+	c.PopStack()
+	if got := run(c, "(yafib 10)", true); got != "55" {
+		t.Errorf("(yafib 10) after cancel = %s, expected 55", got)
+	}
+}